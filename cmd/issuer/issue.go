@@ -76,15 +76,25 @@ func mustGenerateRSAPrivateKey() *rsa.PrivateKey {
 	return key
 }
 
+// mustGenerateSerialNumber returns a random 128-bit serial number, so that certificates
+// issued within the same second can be revoked independently.
+func mustGenerateSerialNumber() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		panic(fmt.Errorf("generate serial number: %w", err))
+	}
+	return serial
+}
+
 func makeCertificateAuthority(subject pkix.Name) (*KeyPair, error) {
 	now := time.Now()
 	cert := &x509.Certificate{
 		Subject:      subject,
-		SerialNumber: big.NewInt(now.Unix()),
+		SerialNumber: mustGenerateSerialNumber(),
 		NotBefore:    now,
 		NotAfter:     now.Add(CADuration),
 		IsCA:         true,
-		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 		ExtKeyUsage: []x509.ExtKeyUsage{
 			x509.ExtKeyUsageServerAuth,
 			x509.ExtKeyUsageClientAuth,
@@ -122,7 +132,7 @@ func makeServerCertificate(
 ) (*KeyPair, error) {
 	now := time.Now()
 	cert := &x509.Certificate{
-		SerialNumber: big.NewInt(now.Unix()),
+		SerialNumber: mustGenerateSerialNumber(),
 		Subject:      subject,
 		DNSNames:     altNames,
 		NotBefore:    now,
@@ -149,7 +159,7 @@ func makeClientCertificate(
 ) (*KeyPair, error) {
 	now := time.Now()
 	cert := &x509.Certificate{
-		SerialNumber: big.NewInt(now.Unix()),
+		SerialNumber: mustGenerateSerialNumber(),
 		Subject:      subject,
 		DNSNames:     altNames,
 		NotBefore:    now,
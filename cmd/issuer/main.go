@@ -11,10 +11,13 @@ const (
 	ActionRotateCA     = "rotate-ca"
 	ActionRotateServer = "rotate-server"
 	ActionRotateClient = "rotate-client"
+	ActionRevoke       = "revoke"
+	ActionRefreshCRL   = "refresh-crl"
 )
 
 type CLI struct {
-	Action Action `arg:"" required:"" enum:"new,rotate-ca,rotate-server,rotate-client" help:"Action to perform"`
+	Action Action `arg:"" required:"" enum:"new,rotate-ca,rotate-server,rotate-client,revoke,refresh-crl" help:"Action to perform"`
+	Serial string `arg:"" optional:"" help:"Serial number of the certificate to revoke, decimal or 0x prefixed hex"`
 }
 
 const (
@@ -25,6 +28,7 @@ const (
 	ServerKeyPath  = "certs/server/tls.key"
 	ClientCertPath = "certs/client/tls.crt"
 	ClientKeyPath  = "certs/client/tls.key"
+	CRLPath        = "certs/ca.crl"
 )
 
 func main() {
@@ -70,6 +74,22 @@ func main() {
 		client := mustMakeClientCertificate(ca)
 		cliCtx.FatalIfErrorf(client.SaveCertificate(ClientCertPath))
 		cliCtx.FatalIfErrorf(client.SavePrivateKey(ClientKeyPath))
+	case ActionRevoke:
+		serial, err := parseSerial(cli.Serial)
+		if err != nil {
+			cliCtx.Fatalf("Failed to revoke: %v", err)
+		}
+		ca, err := loadCertificateAuthority(CACertPath, CAKeyPath)
+		if err != nil {
+			cliCtx.Fatalf("Failed to load CA: %v", err)
+		}
+		cliCtx.FatalIfErrorf(revokeSerial(ca, serial, CRLPath))
+	case ActionRefreshCRL:
+		ca, err := loadCertificateAuthority(CACertPath, CAKeyPath)
+		if err != nil {
+			cliCtx.Fatalf("Failed to load CA: %v", err)
+		}
+		cliCtx.FatalIfErrorf(refreshRevocationList(ca, CRLPath))
 	default:
 		cliCtx.Fatalf("Unknown action: %s", cli.Action)
 	}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+)
+
+const CRLDuration = 24 * time.Hour
+
+// revokeSerial adds the serial to the CRL of the CA at path, and writes the CRL signed
+// with the CA key. Entries of an existing CRL are kept if it was issued by the same CA.
+func revokeSerial(ca *KeyPair, serial *big.Int, path string) error {
+	now := time.Now()
+	template, err := nextRevocationList(ca, path, now)
+	if err != nil {
+		return err
+	}
+	for _, entry := range template.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(serial) == 0 {
+			return fmt.Errorf("serial %s is already revoked", serial)
+		}
+	}
+	template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+		SerialNumber:   serial,
+		RevocationTime: now,
+	})
+	return writeRevocationList(ca, template, path)
+}
+
+// refreshRevocationList re-signs the CRL of the CA at path with a new number and next
+// update, keeping its entries. It writes an empty CRL if there is none yet. Loaders reject
+// peers once the CRL is past its next update, so it must be refreshed within CRLDuration.
+func refreshRevocationList(ca *KeyPair, path string) error {
+	template, err := nextRevocationList(ca, path, time.Now())
+	if err != nil {
+		return err
+	}
+	return writeRevocationList(ca, template, path)
+}
+
+// nextRevocationList returns the template of the next CRL of the CA at path, valid for
+// CRLDuration. The entries of an existing CRL are kept, and its number bumped, if it was
+// issued by the same CA. An existing CRL without a number, which is optional, is followed
+// by number 1.
+func nextRevocationList(ca *KeyPair, path string, now time.Time) (*x509.RevocationList, error) {
+	if ca.Certificate.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return nil, fmt.Errorf("CA certificate %s has no CRL sign key usage, reissue the CA with rotate-ca to sign CRLs", ca.Certificate.Subject)
+	}
+
+	template := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: now,
+		NextUpdate: now.Add(CRLDuration),
+	}
+
+	existing, err := loadRevocationList(path)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.CheckSignatureFrom(ca.Certificate) == nil {
+		if existing.Number != nil {
+			template.Number = new(big.Int).Add(existing.Number, big.NewInt(1))
+		}
+		template.RevokedCertificateEntries = existing.RevokedCertificateEntries
+	}
+	return template, nil
+}
+
+func writeRevocationList(ca *KeyPair, template *x509.RevocationList, path string) error {
+	der, err := x509.CreateRevocationList(rand.Reader, template, ca.Certificate, ca.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("create CRL: %w", err)
+	}
+	buf := new(bytes.Buffer)
+	pem.Encode(buf, &pem.Block{
+		Type:  "X509 CRL",
+		Bytes: der,
+	})
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func loadRevocationList(path string) (*x509.RevocationList, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read CRL: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "X509 CRL" {
+		return nil, fmt.Errorf("read CRL: %s has no X509 CRL block", path)
+	}
+	list, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse CRL: %w", err)
+	}
+	return list, nil
+}
+
+func parseSerial(s string) (*big.Int, error) {
+	serial, ok := new(big.Int).SetString(s, 0)
+	if !ok || serial.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid serial number %q", s)
+	}
+	return serial, nil
+}
@@ -6,30 +6,36 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/zarvd/mtls-demo/internal/keypair"
+	"github.com/zarvd/mtls-demo/internal/securetransport"
 )
 
 type CLI struct {
-	KeyPair keypair.Options `embed:""`
-	Port    int             `required:"" help:"Port to listen on"`
+	KeyPair      keypair.Options `embed:""`
+	Port         int             `required:"" help:"Port to listen on"`
+	AllowedPeers []string        `help:"Common names of the clients allowed to call /ping; any verified client if empty"`
 }
 
 func (c *CLI) Run(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	bundle, err := keypair.NewBundle(c.KeyPair)
+	loader, err := securetransport.NewLocalFileServerTLSConfigLoader(securetransport.LocalFileTLSConfigLoaderOptions{
+		CABundle:    c.KeyPair.CABundle,
+		Certificate: c.KeyPair.Certificate,
+		Key:         c.KeyPair.Key,
+		ClientAuth:  securetransport.VerifyClientCertIfGiven, // Health checks come without a client certificate.
+	})
 	if err != nil {
 		return err
 	}
 
 	eg, ctx := errgroup.WithContext(ctx)
 	eg.Go(func() error {
-		bundle.StartReloadLoop(ctx)
-		return nil
+		return loader.StartLoop(ctx)
 	})
 
 	eg.Go(func() error {
-		return RunHTTPServer(ctx, c.Port, bundle)
+		return RunHTTPServer(ctx, c.Port, loader, c.AllowedPeers)
 	})
 	return eg.Wait()
 }
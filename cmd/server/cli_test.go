@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zarvd/mtls-demo/internal/keypair"
+)
+
+// TestCLI_Run starts the server with certificates as short-lived as the ones of cmd/issuer.
+func TestCLI_Run(t *testing.T) {
+	t.Parallel()
+
+	var (
+		dir      = t.TempDir()
+		now      = time.Now()
+		ca, caKP = issue(t, nil, nil, &x509.Certificate{
+			Subject:               pkix.Name{CommonName: "mtls-ca"},
+			NotBefore:             now,
+			NotAfter:              now.Add(30 * time.Minute),
+			IsCA:                  true,
+			KeyUsage:              x509.KeyUsageCertSign,
+			BasicConstraintsValid: true,
+		})
+		server, serverKey = issue(t, ca, caKP, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "mtls-server"},
+			DNSNames:    []string{"localhost"},
+			NotBefore:   now,
+			NotAfter:    now.Add(10 * time.Minute),
+			KeyUsage:    x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		})
+		cli = &CLI{
+			KeyPair: keypair.Options{
+				CABundle:    writePEM(t, filepath.Join(dir, "ca.crt"), "CERTIFICATE", ca.Raw),
+				Certificate: writePEM(t, filepath.Join(dir, "tls.crt"), "CERTIFICATE", server.Raw),
+				Key:         writePEM(t, filepath.Join(dir, "tls.key"), "PRIVATE KEY", marshalKey(t, serverKey)),
+			},
+			Port: freePort(t),
+		}
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- cli.Run(ctx) }()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "localhost"}}}
+	get := func(path string) (int, error) {
+		resp, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d%s", cli.Port, path))
+		if err != nil {
+			return 0, err
+		}
+		return resp.StatusCode, resp.Body.Close()
+	}
+
+	require.Eventually(t, func() bool {
+		code, err := get("/healthz")
+		return err == nil && code == http.StatusOK
+	}, 5*time.Second, 50*time.Millisecond, "the server should serve health checks without a client certificate")
+	code, err := get("/ping")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	cancel()
+	select {
+	case <-errCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server did not stop")
+	}
+}
+
+func issue(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, template *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template.SerialNumber, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	require.NoError(t, err)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func marshalKey(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return der
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) string {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	return lis.Addr().(*net.TCPAddr).Port
+}
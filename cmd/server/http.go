@@ -2,39 +2,51 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"time"
 
-	"github.com/zarvd/mtls-demo/internal/keypair"
+	"github.com/zarvd/mtls-demo/internal/securetransport"
 )
 
-func RunHTTPServer(ctx context.Context, port int, bundle *keypair.Bundle) error {
+func RunHTTPServer(ctx context.Context, port int, loader securetransport.ServerTLSLoader, allowedPeers []string) error {
 	addr := fmt.Sprintf(":%d", port)
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	requirePeer := securetransport.RequirePeerCertificate(allowCommonNames(allowedPeers))
+	mux.Handle("/ping", requirePeer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		t1 := time.Now()
+		peer, ok := securetransport.PeerIdentityFromContext(r.Context())
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
 		defer func() {
 			slog.Info(
 				"Request handled",
 				slog.String("method", r.Method),
 				slog.String("url", r.URL.String()),
+				slog.Any("peer", peer),
 				slog.Duration("duration", time.Since(t1)),
 			)
 		}()
 
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Hello, World!"))
-	})
+		fmt.Fprintf(w, "Hello, %s!", peer.CommonName)
+	})))
 
-	tlsConfig := bundle.CreateTLSConfigForServer()
+	tlsConfig := loader.ServerTLSConfig()
 
 	server := http.Server{
 		Addr:      addr,
 		TLSConfig: tlsConfig,
-		Handler:   mux,
+		Handler:   securetransport.PeerIdentityMiddleware(mux),
 	}
 	slog.Info("Starting server", slog.String("addr", addr))
 	defer slog.Info("Server stopped")
@@ -48,3 +60,21 @@ func RunHTTPServer(ctx context.Context, port int, bundle *keypair.Bundle) error
 	<-ctx.Done()
 	return server.Shutdown(ctx)
 }
+
+// allowCommonNames returns a PeerVerifier accepting the clients with one of the common
+// names, or nil to accept any verified client.
+func allowCommonNames(commonNames []string) securetransport.PeerVerifier {
+	if len(commonNames) == 0 {
+		return nil
+	}
+	return func(state tls.ConnectionState) error {
+		peer, ok := securetransport.PeerIdentityFromConnectionState(state)
+		if !ok {
+			return fmt.Errorf("no verified client certificate")
+		}
+		if !slices.Contains(commonNames, peer.CommonName) {
+			return fmt.Errorf("client %q is not allowed", peer.CommonName)
+		}
+		return nil
+	}
+}
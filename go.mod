@@ -6,8 +6,12 @@ require (
 	github.com/alecthomas/kong v1.12.1
 	github.com/fsnotify/fsnotify v1.9.0
 	github.com/stretchr/testify v1.10.0
+	github.com/youmark/pkcs8 v0.0.0-20240726163527-a2c0da244d78
+	golang.org/x/crypto v0.38.0
 	golang.org/x/sync v0.16.0
 	google.golang.org/grpc v1.74.2
+	google.golang.org/protobuf v1.36.6
+	software.sslmate.com/src/go-pkcs12 v0.7.3
 )
 
 require (
@@ -17,6 +21,5 @@ require (
 	golang.org/x/sys v0.34.0 // indirect
 	golang.org/x/text v0.25.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250528174236-200df99c418a // indirect
-	google.golang.org/protobuf v1.36.6 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,102 @@
+package mtls
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// atomicWriterDataDir is the symlink the Kubernetes atomic writer swaps to publish a new
+// generation of a Secret or ConfigMap volume. The user visible files are symlinks into it:
+//
+//	tls.crt -> ..data/tls.crt
+//	..data  -> ..2006_01_02_15_04_05.000000000
+const atomicWriterDataDir = "..data"
+
+const maxSnapshotAttempts = 5
+
+var ErrInconsistentSnapshot = errors.New("files kept changing while being read")
+
+// readFilesSnapshot reads the files as one consistent snapshot.
+//
+// Files published by the Kubernetes atomic writer are read from the resolved `..data`
+// target instead of through the `..data` symlink, so that all files of a volume come from
+// the same generation even if the symlink is swapped in between. The targets are resolved
+// again after reading, and the whole snapshot is retried if any of them moved.
+// Files outside of an atomic writer volume are read as they are.
+func readFilesSnapshot(paths []string) ([][]byte, error) {
+	var lastErr error
+	for range maxSnapshotAttempts {
+		before := resolveAtomicWriterTargets(paths)
+		contents, err := readFilesAt(paths, before)
+		after := resolveAtomicWriterTargets(paths)
+		if !maps.Equal(before, after) {
+			// A new generation was published mid-read, and the old one may be gone already.
+			lastErr = err
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return contents, nil
+	}
+	if lastErr != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInconsistentSnapshot, lastErr)
+	}
+	return nil, ErrInconsistentSnapshot
+}
+
+// resolveAtomicWriterTargets returns the current `..data` target of every directory
+// that holds one of the paths and is managed by the atomic writer.
+func resolveAtomicWriterTargets(paths []string) map[string]string {
+	rv := make(map[string]string)
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		if _, ok := rv[dir]; ok {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(dir, atomicWriterDataDir))
+		if err != nil {
+			continue // Not an atomic writer volume.
+		}
+		rv[dir] = target
+	}
+	return rv
+}
+
+func readFilesAt(paths []string, targets map[string]string) ([][]byte, error) {
+	rv := make([][]byte, 0, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(resolveAtomicWriterPath(path, targets))
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, content)
+	}
+	return rv, nil
+}
+
+// resolveAtomicWriterPath rewrites a path that links into `..data` to point into the
+// given `..data` target directly. Other paths are returned unchanged.
+func resolveAtomicWriterPath(path string, targets map[string]string) string {
+	dir := filepath.Dir(path)
+	target, ok := targets[dir]
+	if !ok {
+		return path
+	}
+	link, err := os.Readlink(path)
+	if err != nil {
+		return path // Not a symlink, e.g. a file next to the volume's files.
+	}
+	rest, ok := strings.CutPrefix(filepath.ToSlash(filepath.Clean(link)), atomicWriterDataDir+"/")
+	if !ok {
+		return path
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(dir, target)
+	}
+	return filepath.Join(target, filepath.FromSlash(rest))
+}
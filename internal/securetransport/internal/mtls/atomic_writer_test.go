@@ -0,0 +1,137 @@
+package mtls
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// publishAtomicWriterGeneration mimics how the kubelet publishes a new generation of a
+// Secret volume: the files are written to a fresh hidden directory, the `..data` symlink
+// is swapped to it and the previous generation is removed.
+func publishAtomicWriterGeneration(t *testing.T, dir, generation string, files map[string][]byte) {
+	t.Helper()
+
+	genDir := filepath.Join(dir, ".."+generation)
+	require.NoError(t, os.Mkdir(genDir, 0700))
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(genDir, name), content, 0600))
+	}
+
+	previous, _ := os.Readlink(filepath.Join(dir, atomicWriterDataDir))
+	tmpLink := filepath.Join(dir, "..data_tmp")
+	require.NoError(t, os.Symlink(".."+generation, tmpLink))
+	require.NoError(t, os.Rename(tmpLink, filepath.Join(dir, atomicWriterDataDir)))
+
+	for name := range files {
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			require.NoError(t, os.Symlink(filepath.Join(atomicWriterDataDir, name), link))
+		}
+	}
+	if previous != "" {
+		require.NoError(t, os.RemoveAll(filepath.Join(dir, previous)))
+	}
+}
+
+func TestReadFilesSnapshot(t *testing.T) {
+	t.Parallel()
+
+	t.Run("it should read plain files", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "b"), []byte("b"), 0600))
+
+		contents, err := readFilesSnapshot([]string{filepath.Join(dir, "a"), filepath.Join(dir, "b")})
+		require.NoError(t, err)
+		assert.Equal(t, [][]byte{[]byte("a"), []byte("b")}, contents)
+	})
+
+	t.Run("it should read every file from the resolved data directory", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		publishAtomicWriterGeneration(t, dir, "v1", map[string][]byte{
+			"tls.crt": []byte("cert-v1"),
+			"tls.key": []byte("key-v1"),
+		})
+		paths := []string{filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")}
+
+		targets := resolveAtomicWriterTargets(paths)
+		assert.Equal(t, map[string]string{dir: "..v1"}, targets)
+		assert.Equal(t, filepath.Join(dir, "..v1", "tls.crt"), resolveAtomicWriterPath(paths[0], targets))
+
+		contents, err := readFilesSnapshot(paths)
+		require.NoError(t, err)
+		assert.Equal(t, [][]byte{[]byte("cert-v1"), []byte("key-v1")}, contents)
+
+		publishAtomicWriterGeneration(t, dir, "v2", map[string][]byte{
+			"tls.crt": []byte("cert-v2"),
+			"tls.key": []byte("key-v2"),
+		})
+		contents, err = readFilesSnapshot(paths)
+		require.NoError(t, err)
+		assert.Equal(t, [][]byte{[]byte("cert-v2"), []byte("key-v2")}, contents)
+	})
+
+	t.Run("it should return an error for missing files", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := readFilesSnapshot([]string{filepath.Join(t.TempDir(), "missing")})
+		require.Error(t, err)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestLocalFileTLSConfigLoader_AtomicWriter(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca            = fakeCA(fakeCATemplate())
+		keyPair       = ca.Sign(fakeServerTemplate())
+		secondKeyPair = ca.Sign(fakeServerTemplate())
+		volume        = func(kp *TLSKeyPair) map[string][]byte {
+			return map[string][]byte{
+				"ca.crt":  ToCertificatePEM(ca.Certificate.Raw),
+				"tls.crt": ToCertificatePEM(kp.Certificate.Leaf.Raw),
+				"tls.key": ToPrivateKeyPEM(kp.Certificate.PrivateKey),
+			}
+		}
+	)
+
+	dir := t.TempDir()
+	publishAtomicWriterGeneration(t, dir, "v1", volume(keyPair))
+
+	loader, err := NewLocalFileTLSConfigLoader(LocalFileTLSConfigLoaderOptions{
+		CABundle:       filepath.Join(dir, "ca.crt"),
+		Certificate:    filepath.Join(dir, "tls.crt"),
+		Key:            filepath.Join(dir, "tls.key"),
+		ReloadInterval: 1 * time.Hour,
+		Validate:       ValidateKeyPairForServerUsage,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, keyPair.Certificate.Leaf.Raw, loader.KeyPair().Certificate.Leaf.Raw)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- loader.StartLoop(ctx)
+	}()
+
+	publishAtomicWriterGeneration(t, dir, "v2", volume(secondKeyPair))
+	assert.Eventually(t, func() bool {
+		return string(loader.KeyPair().Certificate.Leaf.Raw) == string(secondKeyPair.Certificate.Leaf.Raw)
+	}, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-errC)
+}
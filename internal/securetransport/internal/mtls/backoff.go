@@ -0,0 +1,28 @@
+package mtls
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+var (
+	DefaultRetryBackoff    = 1 * time.Second
+	DefaultMaxRetryBackoff = 2 * time.Minute
+)
+
+// retryBackoff returns the delay before the next attempt after the given number
+// of consecutive failures. The delay doubles with every failure up to max, and
+// is jittered into [d/2, d) so that many sidecars sharing a broken rotation
+// don't retry in lockstep.
+func retryBackoff(failures int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 1; i < failures && d < max; i++ {
+		d *= 2
+	}
+	d = min(d, max)
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + rand.N(half)
+}
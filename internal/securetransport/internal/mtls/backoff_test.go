@@ -0,0 +1,37 @@
+package mtls
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	t.Parallel()
+
+	const (
+		Base = 1 * time.Second
+		Max  = 10 * time.Second
+	)
+
+	tests := []struct {
+		Failures int
+		Expected time.Duration // upper bound, the lower bound is half of it
+	}{
+		{Failures: 1, Expected: 1 * time.Second},
+		{Failures: 2, Expected: 2 * time.Second},
+		{Failures: 3, Expected: 4 * time.Second},
+		{Failures: 4, Expected: 8 * time.Second},
+		{Failures: 5, Expected: 10 * time.Second},
+		{Failures: 100, Expected: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 100 {
+			d := retryBackoff(tt.Failures, Base, Max)
+			assert.GreaterOrEqual(t, d, tt.Expected/2, "failures=%d", tt.Failures)
+			assert.Less(t, d, tt.Expected, "failures=%d", tt.Failures)
+		}
+	}
+}
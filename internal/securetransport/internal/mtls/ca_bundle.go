@@ -0,0 +1,163 @@
+package mtls
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+var ErrCABundleRejected = errors.New("CA bundle rejected")
+
+// CABundleIssue is a problem found with a certificate of a CA bundle.
+type CABundleIssue string
+
+const (
+	CABundleExpired     CABundleIssue = "expired"       // The CA certificate has expired
+	CABundleNotYetValid CABundleIssue = "not-yet-valid" // The CA certificate is not valid yet
+	CABundleNotCA       CABundleIssue = "not-ca"        // The certificate is not a CA certificate
+	CABundleDuplicate   CABundleIssue = "duplicate"     // The certificate appears earlier in the bundle
+	CABundleUnparseable CABundleIssue = "unparseable"   // The PEM block is not a valid certificate
+)
+
+// CABundleAction is what the loaders do about a CABundleIssue.
+type CABundleAction int
+
+const (
+	CABundleWarn   CABundleAction = iota // Log the finding and keep the certificate
+	CABundleDrop                         // Log the finding and leave the certificate out of the pool
+	CABundleReject                       // Reject the whole key pair, keeping the one in use
+)
+
+func (a CABundleAction) String() string {
+	switch a {
+	case CABundleWarn:
+		return "warn"
+	case CABundleDrop:
+		return "drop"
+	case CABundleReject:
+		return "reject"
+	default:
+		return fmt.Sprintf("CABundleAction(%d)", int(a))
+	}
+}
+
+// CABundlePolicy sets the action for each issue found in the CA bundle. The zero value
+// warns about every issue, and otherwise trusts the bundle as it is.
+type CABundlePolicy struct {
+	Expired     CABundleAction // Action for expired CA certificates
+	NotYetValid CABundleAction // Action for CA certificates that are not valid yet
+	NotCA       CABundleAction // Action for certificates that are not CAs
+	Duplicate   CABundleAction // Action for repeated certificates
+	Unparseable CABundleAction // Action for CERTIFICATE blocks that can't be parsed; they are never trusted
+}
+
+func (p *CABundlePolicy) action(issue CABundleIssue) CABundleAction {
+	switch issue {
+	case CABundleExpired:
+		return p.Expired
+	case CABundleNotYetValid:
+		return p.NotYetValid
+	case CABundleNotCA:
+		return p.NotCA
+	case CABundleDuplicate:
+		return p.Duplicate
+	default:
+		return p.Unparseable
+	}
+}
+
+// CABundleFinding is an issue found with a certificate of a CA bundle, and what was done about it.
+type CABundleFinding struct {
+	Issue   CABundleIssue
+	Action  CABundleAction
+	Index   int    // Index of the PEM block in the bundle, starting at 0
+	Subject string // Subject of the certificate, empty if unparseable
+	Detail  string
+}
+
+func (f CABundleFinding) String() string {
+	return fmt.Sprintf("%s certificate #%d %q: %s (%s)", f.Issue, f.Index, f.Subject, f.Detail, f.Action)
+}
+
+// logCABundleFindings logs a warning for every finding, with the given attributes first,
+// e.g. the path of the bundle.
+func logCABundleFindings(logger *slog.Logger, findings []CABundleFinding, attrs ...slog.Attr) {
+	for _, finding := range findings {
+		logger.LogAttrs(context.Background(), slog.LevelWarn, "Found issue in CA bundle", append(attrs,
+			slog.String("issue", string(finding.Issue)),
+			slog.String("action", finding.Action.String()),
+			slog.Int("index", finding.Index),
+			slog.String("subject", finding.Subject),
+			slog.String("detail", finding.Detail),
+		)...)
+	}
+}
+
+// parseCABundle builds the CA pool from the PEM bundle, applying the policy to the issues
+// found. Blocks that are not CERTIFICATE blocks are ignored, like x509.CertPool does.
+func parseCABundle(data []byte, policy CABundlePolicy, now time.Time) (*x509.CertPool, []CABundleFinding, error) {
+	var (
+		pool     = x509.NewCertPool()
+		findings []CABundleFinding
+		seen     = map[string]bool{}
+		trusted  int
+	)
+	for index := 0; ; index++ {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" || len(block.Headers) != 0 {
+			continue
+		}
+
+		var issues []CABundleFinding
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			issues = append(issues, CABundleFinding{Issue: CABundleUnparseable, Detail: err.Error()})
+		} else {
+			switch {
+			case seen[string(cert.Raw)]:
+				issues = append(issues, CABundleFinding{Issue: CABundleDuplicate, Detail: "appears earlier in the bundle"})
+			case !cert.IsCA:
+				issues = append(issues, CABundleFinding{Issue: CABundleNotCA, Detail: "not a CA certificate"})
+			}
+			if now.After(cert.NotAfter) {
+				issues = append(issues, CABundleFinding{Issue: CABundleExpired, Detail: fmt.Sprintf("expired at %s", cert.NotAfter)})
+			}
+			if now.Before(cert.NotBefore) {
+				issues = append(issues, CABundleFinding{Issue: CABundleNotYetValid, Detail: fmt.Sprintf("not valid until %s", cert.NotBefore)})
+			}
+			seen[string(cert.Raw)] = true
+		}
+
+		keep := cert != nil
+		for _, issue := range issues {
+			issue.Index = index
+			issue.Action = policy.action(issue.Issue)
+			if cert != nil {
+				issue.Subject = cert.Subject.String()
+			}
+			if issue.Action == CABundleReject {
+				return nil, nil, fmt.Errorf("%w: %s", ErrCABundleRejected, issue)
+			}
+			if issue.Action == CABundleDrop {
+				keep = false
+			}
+			findings = append(findings, issue)
+		}
+		if keep {
+			pool.AddCert(cert)
+			trusted++
+		}
+	}
+	if trusted == 0 {
+		return nil, nil, fmt.Errorf("%w: no trusted certificate left in the CA bundle", ErrAppendCACertsFromPEM)
+	}
+	return pool, findings, nil
+}
@@ -0,0 +1,68 @@
+package mtls
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+type CABundleLoaderOptions struct {
+	FileLoaderOptions                // Path is the CA bundle PEM file
+	Policy            CABundlePolicy // Warn about, drop or reject problematic CA certificates; warns by default
+}
+
+// CABundleReloadEvent describes the outcome of a bundle reload attempt that found new
+// content.
+type CABundleReloadEvent = FileReloadEvent[x509.CertPool]
+
+// CABundleLoader keeps a CA pool loaded from a PEM file up to date, e.g. the bundle that
+// verifies the clients of a tenant. A bundle that fails to parse is logged and the previous
+// pool stays in use.
+type CABundleLoader struct {
+	options CABundleLoaderOptions
+	file    *fileLoader[x509.CertPool]
+}
+
+func NewCABundleLoader(options CABundleLoaderOptions) (*CABundleLoader, error) {
+	if err := checkFile(options.Path); err != nil {
+		return nil, fmt.Errorf("check CA bundle file: %w", err)
+	}
+	if err := options.defaults(); err != nil {
+		return nil, err
+	}
+	loader := &CABundleLoader{options: options}
+	loader.file = &fileLoader[x509.CertPool]{
+		name:    "CA bundle",
+		options: options.FileLoaderOptions,
+		parse:   loader.parseBundle,
+	}
+	if err := loader.file.load(); err != nil {
+		return nil, err
+	}
+	return loader, nil
+}
+
+// StartLoop reloads the bundle whenever the file changes until the context is cancelled.
+func (l *CABundleLoader) StartLoop(ctx context.Context) error {
+	return l.file.StartLoop(ctx)
+}
+
+// Watch returns a channel of reload events, which is closed when the context is cancelled.
+func (l *CABundleLoader) Watch(ctx context.Context) <-chan CABundleReloadEvent {
+	return l.file.Watch(ctx)
+}
+
+func (l *CABundleLoader) CAPool() *x509.CertPool {
+	return l.file.Load()
+}
+
+func (l *CABundleLoader) parseBundle(_ []string, contents [][]byte) (*x509.CertPool, error) {
+	pool, findings, err := parseCABundle(contents[0], l.options.Policy, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	logCABundleFindings(l.options.Logger, findings, slog.String("path", l.options.Path))
+	return pool, nil
+}
@@ -0,0 +1,129 @@
+package mtls
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCABundleLoader(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca         = fakeCA(fakeCATemplate())
+		rotated    = fakeCA(fakeCATemplate())
+		bundleFile = filepath.Join(t.TempDir(), "ca.pem")
+	)
+	writeBundle := func(data []byte) {
+		tmp := bundleFile + ".tmp"
+		require.NoError(t, os.WriteFile(tmp, data, 0o600))
+		require.NoError(t, os.Rename(tmp, bundleFile))
+	}
+	writeBundle(ToCertificatePEM(ca.Certificate.Raw))
+
+	loader, err := NewCABundleLoader(CABundleLoaderOptions{FileLoaderOptions: FileLoaderOptions{
+		Path:           bundleFile,
+		ReloadInterval: 50 * time.Millisecond,
+		WatchDebounce:  10 * time.Millisecond,
+	}})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = loader.StartLoop(ctx) }()
+
+	t.Log("the bundle is loaded")
+	assert.True(t, loader.CAPool().Equal(ca.pool()))
+
+	t.Log("the bundle is reloaded once the file is changed")
+	writeBundle(ToCertificatePEM(rotated.Certificate.Raw))
+	assert.Eventually(t, func() bool { return loader.CAPool().Equal(rotated.pool()) }, 2*time.Second, 20*time.Millisecond)
+
+	t.Log("an invalid bundle is ignored and the previous one stays in use")
+	writeBundle([]byte("not a bundle"))
+	time.Sleep(200 * time.Millisecond)
+	assert.True(t, loader.CAPool().Equal(rotated.pool()))
+}
+
+func TestCABundleLoader_StartLoop(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca          = fakeCA(fakeCATemplate())
+		rotated     = fakeCA(fakeCATemplate())
+		bundleFile  = filepath.Join(t.TempDir(), "ca.pem")
+		writeBundle = func(data []byte) {
+			tmp := bundleFile + ".tmp"
+			require.NoError(t, os.WriteFile(tmp, data, 0o600))
+			require.NoError(t, os.Rename(tmp, bundleFile))
+		}
+		onErrorCalls atomic.Int32
+	)
+	writeBundle(ToCertificatePEM(ca.Certificate.Raw))
+
+	loader, err := NewCABundleLoader(CABundleLoaderOptions{FileLoaderOptions: FileLoaderOptions{
+		Path:                   bundleFile,
+		ReloadInterval:         50 * time.Millisecond,
+		WatchDebounce:          10 * time.Millisecond,
+		OnError:                func(error) { onErrorCalls.Add(1) },
+		MaxConsecutiveFailures: 3,
+		RetryBackoff:           10 * time.Millisecond,
+		MaxRetryBackoff:        20 * time.Millisecond,
+	}})
+	require.NoError(t, err)
+	initial := loader.CAPool()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := loader.Watch(ctx)
+	errC := make(chan error, 1)
+	go func() { errC <- loader.StartLoop(ctx) }()
+
+	t.Log("a new bundle is reported to the subscribers")
+	writeBundle(ToCertificatePEM(rotated.Certificate.Raw))
+	event := <-events
+	require.True(t, event.Succeeded())
+	assert.Equal(t, uint64(2), event.Generation)
+	assert.Same(t, initial, event.Previous)
+	assert.Same(t, loader.CAPool(), event.Current)
+
+	t.Log("an invalid bundle is retried with backoff until the loop gives up")
+	writeBundle([]byte("not a bundle"))
+	event = <-events
+	require.Error(t, event.Err)
+	assert.Equal(t, uint64(2), event.Generation)
+	assert.Same(t, event.Previous, event.Current)
+
+	select {
+	case err := <-errC:
+		require.ErrorIs(t, err, ErrTooManyReloadFailures)
+	case <-time.After(2 * time.Second):
+		t.Fatal("StartLoop did not give up")
+	}
+	assert.Equal(t, int32(3), onErrorCalls.Load())
+	assert.True(t, loader.CAPool().Equal(rotated.pool()))
+}
+
+func TestNewCABundleLoader(t *testing.T) {
+	t.Parallel()
+
+	t.Run("it should fail if the bundle file does not exist", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewCABundleLoader(CABundleLoaderOptions{FileLoaderOptions: FileLoaderOptions{Path: filepath.Join(t.TempDir(), "missing.pem")}})
+		require.Error(t, err)
+	})
+
+	t.Run("it should fail if the bundle is rejected by the policy", func(t *testing.T) {
+		t.Parallel()
+		ca := fakeCA(fakeCATemplate())
+		path := filepath.Join(t.TempDir(), "ca.pem")
+		require.NoError(t, os.WriteFile(path, append(ToCertificatePEM(ca.Certificate.Raw), ToCertificatePEM(ca.Certificate.Raw)...), 0o600))
+		_, err := NewCABundleLoader(CABundleLoaderOptions{FileLoaderOptions: FileLoaderOptions{Path: path}, Policy: CABundlePolicy{Duplicate: CABundleReject}})
+		require.ErrorIs(t, err, ErrCABundleRejected)
+	})
+}
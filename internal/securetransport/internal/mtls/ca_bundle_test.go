@@ -0,0 +1,113 @@
+package mtls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCABundle(t *testing.T) {
+	t.Parallel()
+
+	var (
+		now     = time.Now()
+		ca      = fakeCA(fakeCATemplate())
+		expired = fakeCA(fakeCATemplate(func(template *x509.Certificate) {
+			template.Subject.CommonName = "test-expired-ca"
+			template.NotBefore = now.Add(-2 * time.Hour)
+			template.NotAfter = now.Add(-time.Hour)
+		}))
+		leaf   = ca.Sign(fakeServerTemplate()).Certificate.Leaf
+		bundle = bytes.Join([][]byte{
+			ToCertificatePEM(ca.Certificate.Raw),
+			ToCertificatePEM(expired.Certificate.Raw),
+			ToCertificatePEM(ca.Certificate.Raw),
+			ToCertificatePEM(leaf.Raw),
+			pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("junk")}),
+			pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: []byte("ignored")}),
+		}, nil)
+		issues = func(findings []CABundleFinding) []CABundleIssue {
+			var rv []CABundleIssue
+			for _, finding := range findings {
+				rv = append(rv, finding.Issue)
+			}
+			return rv
+		}
+		poolOf = func(certs ...*x509.Certificate) *x509.CertPool {
+			pool := x509.NewCertPool()
+			for _, cert := range certs {
+				pool.AddCert(cert)
+			}
+			return pool
+		}
+	)
+
+	t.Run("it should warn about every issue by default", func(t *testing.T) {
+		t.Parallel()
+
+		pool, findings, err := parseCABundle(bundle, CABundlePolicy{}, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, []CABundleIssue{CABundleExpired, CABundleDuplicate, CABundleNotCA, CABundleUnparseable}, issues(findings))
+		assert.Equal(t, []int{1, 2, 3, 4}, []int{findings[0].Index, findings[1].Index, findings[2].Index, findings[3].Index})
+		assert.Equal(t, "CN=test-expired-ca", findings[0].Subject)
+		for _, finding := range findings {
+			assert.Equal(t, CABundleWarn, finding.Action)
+		}
+		assert.True(t, pool.Equal(poolOf(ca.Certificate, expired.Certificate, leaf)))
+	})
+
+	t.Run("it should drop certificates", func(t *testing.T) {
+		t.Parallel()
+
+		pool, findings, err := parseCABundle(bundle, CABundlePolicy{
+			Expired: CABundleDrop,
+			NotCA:   CABundleDrop,
+		}, time.Now())
+		require.NoError(t, err)
+		assert.Len(t, findings, 4)
+		assert.True(t, pool.Equal(poolOf(ca.Certificate)))
+	})
+
+	t.Run("it should reject the bundle", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := parseCABundle(bundle, CABundlePolicy{Unparseable: CABundleReject}, time.Now())
+		require.ErrorIs(t, err, ErrCABundleRejected)
+		assert.ErrorContains(t, err, "unparseable certificate #4")
+	})
+
+	t.Run("it should fail if no certificate is left", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := parseCABundle(ToCertificatePEM(expired.Certificate.Raw), CABundlePolicy{Expired: CABundleDrop}, time.Now())
+		assert.ErrorIs(t, err, ErrAppendCACertsFromPEM)
+	})
+}
+
+func TestSourceTLSConfigLoader_CABundlePolicy(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca      = fakeCA(fakeCATemplate())
+		keyPair = ca.Sign(fakeServerTemplate())
+		leafPEM = ToCertificatePEM(keyPair.Certificate.Leaf.Raw)
+		keyPEM  = ToPrivateKeyPEM(keyPair.Certificate.PrivateKey)
+		caPEM   = ToCertificatePEM(ca.Certificate.Raw)
+	)
+	source := &fakeKeyPairSource{raw: NewTLSKeyPairRaw(bytes.Join([][]byte{caPEM, caPEM}, nil), leafPEM, keyPEM)}
+
+	loader, err := NewSourceServerTLSConfigLoader(source, SourceTLSConfigLoaderOptions{})
+	require.NoError(t, err)
+	require.Len(t, loader.loader.KeyPair().CABundleFindings, 1)
+	assert.Equal(t, CABundleDuplicate, loader.loader.KeyPair().CABundleFindings[0].Issue)
+
+	_, err = NewSourceServerTLSConfigLoader(source, SourceTLSConfigLoaderOptions{
+		CABundlePolicy: CABundlePolicy{Duplicate: CABundleReject},
+	})
+	assert.ErrorIs(t, err, ErrCABundleRejected)
+}
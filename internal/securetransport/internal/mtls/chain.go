@@ -0,0 +1,81 @@
+package mtls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+var ErrIncompleteCertificateChain = errors.New("incomplete certificate chain")
+
+// normalizeCertificateChain orders the certificates of the chain from the issuer of the
+// leaf upwards. Self-signed roots are dropped since peers must already trust them, and so
+// are certificates that don't belong to the chain of the leaf.
+func normalizeCertificateChain(leaf *x509.Certificate, certs []*x509.Certificate) []*x509.Certificate {
+	var (
+		chain   []*x509.Certificate
+		current = leaf
+		used    = make([]bool, len(certs))
+	)
+	for {
+		next := -1
+		for i, cert := range certs {
+			if !used[i] && isIssuerOf(cert, current) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return chain
+		}
+		used[next] = true
+		if isSelfSigned(certs[next]) {
+			return chain
+		}
+		chain = append(chain, certs[next])
+		current = certs[next]
+	}
+}
+
+func isIssuerOf(issuer, cert *x509.Certificate) bool {
+	return bytes.Equal(issuer.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(issuer) == nil
+}
+
+// intermediates returns the pool of the certificates presented after the leaf.
+func (k *TLSKeyPair) intermediates() (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, der := range k.Certificate.Certificate[1:] {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate chain: %w", err)
+		}
+		pool.AddCert(cert)
+	}
+	return pool, nil
+}
+
+// verifyCertificateChain verifies the leaf against the CA bundle, with the intermediates
+// of the chain, for the usage.
+func verifyCertificateChain(keyPair *TLSKeyPair, usage x509.ExtKeyUsage) ([][]*x509.Certificate, error) {
+	intermediates, err := keyPair.intermediates()
+	if err != nil {
+		return nil, err
+	}
+	leaf := keyPair.Certificate.Leaf
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         keyPair.CAs,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	})
+	var unknownAuthority x509.UnknownAuthorityError
+	if errors.As(err, &unknownAuthority) {
+		top := leaf
+		if n := len(keyPair.Certificate.Certificate); n > 1 {
+			top, _ = x509.ParseCertificate(keyPair.Certificate.Certificate[n-1])
+		}
+		return nil, fmt.Errorf("%w: issuer %q of %q is neither in the chain nor in the CA bundle",
+			ErrIncompleteCertificateChain, top.Issuer, top.Subject)
+	}
+	return chains, err
+}
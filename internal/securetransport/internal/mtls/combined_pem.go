@@ -0,0 +1,82 @@
+package mtls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrSplitCombinedPEM = errors.New("split combined PEM")
+
+// splitCombinedPEM splits a PEM file holding a private key, a leaf certificate and its
+// chain, in any order, into the CA bundle, certificate chain and key PEMs that make up a
+// TLSKeyPairRaw.
+//
+// The leaf is the first certificate that is not a CA, and is followed by the remaining
+// certificates in file order. When caInBundle is set, self-signed CA certificates are
+// moved to the CA bundle instead of the chain. Unknown PEM blocks are ignored.
+func splitCombinedPEM(data []byte, caInBundle bool) (caPEM, certPEM, keyPEM []byte, err error) {
+	var (
+		leaf   *pem.Block
+		chain  []*pem.Block
+		roots  []*pem.Block
+		keys   []*pem.Block
+		blocks int
+	)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		blocks++
+		switch {
+		case isPrivateKeyBlock(block):
+			keys = append(keys, block)
+		case block.Type == "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("%w: parse certificate #%d: %w", ErrSplitCombinedPEM, blocks, err)
+			}
+			switch {
+			case leaf == nil && !cert.IsCA:
+				leaf = block
+			case caInBundle && cert.IsCA && isSelfSigned(cert):
+				roots = append(roots, block)
+			default:
+				chain = append(chain, block)
+			}
+		}
+	}
+
+	if len(keys) != 1 {
+		return nil, nil, nil, fmt.Errorf("%w: expected exactly one private key, found %d", ErrSplitCombinedPEM, len(keys))
+	}
+	if leaf == nil {
+		return nil, nil, nil, fmt.Errorf("%w: no leaf certificate found", ErrSplitCombinedPEM)
+	}
+	if caInBundle && len(roots) == 0 {
+		return nil, nil, nil, fmt.Errorf("%w: no self-signed CA certificate found", ErrSplitCombinedPEM)
+	}
+
+	return encodePEMBlocks(roots), encodePEMBlocks(append([]*pem.Block{leaf}, chain...)), pem.EncodeToMemory(keys[0]), nil
+}
+
+func isPrivateKeyBlock(block *pem.Block) bool {
+	return block.Type == "PRIVATE KEY" || strings.HasSuffix(block.Type, " PRIVATE KEY")
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil
+}
+
+func encodePEMBlocks(blocks []*pem.Block) []byte {
+	buf := new(bytes.Buffer)
+	for _, block := range blocks {
+		pem.Encode(buf, block)
+	}
+	return buf.Bytes()
+}
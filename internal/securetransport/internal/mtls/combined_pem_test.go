@@ -0,0 +1,189 @@
+package mtls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitCombinedPEM(t *testing.T) {
+	t.Parallel()
+
+	var (
+		root         = fakeCA(fakeCATemplate())
+		intermediate = root.SignIntermediate(fakeCATemplate(func(template *x509.Certificate) {
+			template.Subject.CommonName = "test-intermediate"
+		}))
+		keyPair = intermediate.Sign(fakeServerTemplate())
+
+		rootPEM         = ToCertificatePEM(root.Certificate.Raw)
+		intermediatePEM = ToCertificatePEM(intermediate.Certificate.Raw)
+		leafPEM         = ToCertificatePEM(keyPair.Certificate.Leaf.Raw)
+		keyPEM          = ToPrivateKeyPEM(keyPair.Certificate.PrivateKey)
+		join            = func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+	)
+
+	tests := []struct {
+		Name          string
+		Combined      []byte
+		CAInBundle    bool
+		ExpectedCA    []byte
+		ExpectedCert  []byte
+		ExpectedError string
+	}{
+		{
+			Name:         "key first",
+			Combined:     join(keyPEM, leafPEM, intermediatePEM),
+			ExpectedCert: join(leafPEM, intermediatePEM),
+		},
+		{
+			Name:         "key last",
+			Combined:     join(leafPEM, intermediatePEM, keyPEM),
+			ExpectedCert: join(leafPEM, intermediatePEM),
+		},
+		{
+			Name:         "leaf after the chain",
+			Combined:     join(intermediatePEM, keyPEM, leafPEM),
+			ExpectedCert: join(leafPEM, intermediatePEM),
+		},
+		{
+			Name:         "root kept in the chain",
+			Combined:     join(leafPEM, intermediatePEM, rootPEM, keyPEM),
+			ExpectedCert: join(leafPEM, intermediatePEM, rootPEM),
+		},
+		{
+			Name:         "root moved to the CA bundle",
+			Combined:     join(leafPEM, intermediatePEM, rootPEM, keyPEM),
+			CAInBundle:   true,
+			ExpectedCA:   rootPEM,
+			ExpectedCert: join(leafPEM, intermediatePEM),
+		},
+		{
+			Name:          "no root in CA-in-bundle mode",
+			Combined:      join(leafPEM, intermediatePEM, keyPEM),
+			CAInBundle:    true,
+			ExpectedError: "no self-signed CA certificate found",
+		},
+		{
+			Name:          "no private key",
+			Combined:      join(leafPEM, intermediatePEM),
+			ExpectedError: "expected exactly one private key, found 0",
+		},
+		{
+			Name:          "two private keys",
+			Combined:      join(keyPEM, leafPEM, keyPEM),
+			ExpectedError: "expected exactly one private key, found 2",
+		},
+		{
+			Name:          "no leaf certificate",
+			Combined:      join(keyPEM, intermediatePEM),
+			ExpectedError: "no leaf certificate found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+
+			caPEM, certPEM, gotKeyPEM, err := splitCombinedPEM(tt.Combined, tt.CAInBundle)
+			if tt.ExpectedError != "" {
+				require.ErrorIs(t, err, ErrSplitCombinedPEM)
+				assert.ErrorContains(t, err, tt.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.ExpectedCA, caPEM)
+			assert.Equal(t, tt.ExpectedCert, certPEM)
+			assert.Equal(t, keyPEM, gotKeyPEM)
+		})
+	}
+}
+
+func TestNewLocalFileTLSConfigLoader_CombinedPEM(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca      = fakeCA(fakeCATemplate())
+		keyPair = ca.Sign(fakeServerTemplate())
+		caPEM   = ToCertificatePEM(ca.Certificate.Raw)
+	)
+
+	t.Run("it should load the key pair from a combined PEM and a separate CA bundle", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		combined := filepath.Join(dir, "combined.pem")
+		bundle := filepath.Join(dir, "ca.pem")
+		require.NoError(t, os.WriteFile(combined, bytes.Join([][]byte{
+			ToPrivateKeyPEM(keyPair.Certificate.PrivateKey),
+			ToCertificatePEM(keyPair.Certificate.Leaf.Raw),
+		}, nil), 0600))
+		require.NoError(t, os.WriteFile(bundle, caPEM, 0600))
+
+		loader, err := NewLocalFileTLSConfigLoader(LocalFileTLSConfigLoaderOptions{
+			CABundle:    bundle,
+			CombinedPEM: combined,
+			Validate:    ValidateKeyPairForServerUsage,
+		})
+		require.NoError(t, err)
+		assert.True(t, loader.KeyPair().Equal(keyPair))
+	})
+
+	t.Run("it should load the CA bundle from the combined PEM", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		combined := filepath.Join(dir, "combined.pem")
+		require.NoError(t, os.WriteFile(combined, bytes.Join([][]byte{
+			ToCertificatePEM(keyPair.Certificate.Leaf.Raw),
+			caPEM,
+			ToPrivateKeyPEM(keyPair.Certificate.PrivateKey),
+		}, nil), 0600))
+
+		loader, err := NewLocalFileTLSConfigLoader(LocalFileTLSConfigLoaderOptions{
+			CombinedPEM:    combined,
+			CAInCombined:   true,
+			ReloadInterval: 1 * time.Hour,
+			Validate:       ValidateKeyPairForServerUsage,
+		})
+		require.NoError(t, err)
+		assert.True(t, loader.KeyPair().Equal(keyPair))
+		assert.Equal(t, []string{combined}, loader.source.options.filePaths())
+	})
+
+	t.Run("it should reject conflicting options", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		combined := filepath.Join(dir, "combined.pem")
+		require.NoError(t, os.WriteFile(combined, nil, 0600))
+
+		_, err := NewLocalFileTLSConfigLoader(LocalFileTLSConfigLoaderOptions{
+			CABundle:    combined,
+			CombinedPEM: combined,
+			Certificate: combined,
+			Validate:    ValidateKeyPairForServerUsage,
+		})
+		assert.ErrorContains(t, err, "must not be set together with combined PEM")
+
+		_, err = NewLocalFileTLSConfigLoader(LocalFileTLSConfigLoaderOptions{
+			CombinedPEM:  combined,
+			CABundle:     combined,
+			CAInCombined: true,
+			Validate:     ValidateKeyPairForServerUsage,
+		})
+		assert.ErrorContains(t, err, "must not be set together with CA in combined PEM")
+
+		_, err = NewLocalFileTLSConfigLoader(LocalFileTLSConfigLoaderOptions{
+			CAInCombined: true,
+			Validate:     ValidateKeyPairForServerUsage,
+		})
+		assert.ErrorContains(t, err, "requires a combined PEM file")
+	})
+}
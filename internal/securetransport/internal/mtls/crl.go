@@ -0,0 +1,265 @@
+package mtls
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrParseCRL           = errors.New("parse CRL")
+	ErrCertificateRevoked = errors.New("certificate is revoked")
+	ErrStaleCRL           = errors.New("CRL is past its next update")
+)
+
+type CRLLoaderOptions struct {
+	FileLoaderOptions      // Path is a CRL file, or a directory of CRL files; PEM or DER encoded
+	AllowStale        bool // Keep checking peers against CRLs past their next update; they are rejected by default
+}
+
+// CRLReloadEvent describes the outcome of a CRL reload attempt that found new content.
+type CRLReloadEvent = FileReloadEvent[CRLSet]
+
+// CRLLoader keeps the certificate revocation lists of a file or directory up to date,
+// and rejects revoked peers through VerifyPeer. If any CRL fails to parse, the whole
+// reload is rejected and the previous lists stay in use. Peers whose issuer's CRL is past
+// its next update are rejected unless AllowStale is set, so a CRL that stops being
+// refreshed fails closed.
+type CRLLoader struct {
+	options CRLLoaderOptions
+	file    *fileLoader[CRLSet]
+}
+
+func NewCRLLoader(options CRLLoaderOptions) (*CRLLoader, error) {
+	if _, err := os.Stat(options.Path); err != nil {
+		return nil, fmt.Errorf("check CRL path: %w", err)
+	}
+	if err := options.defaults(); err != nil {
+		return nil, err
+	}
+	loader := &CRLLoader{options: options}
+	loader.file = &fileLoader[CRLSet]{
+		name:    "CRLs",
+		options: options.FileLoaderOptions,
+		list:    loader.paths,
+		parse:   loader.parseFiles,
+		attrs: func(crls *CRLSet) []slog.Attr {
+			return []slog.Attr{slog.Int("crls", crls.count)}
+		},
+	}
+	if err := loader.file.load(); err != nil {
+		return nil, err
+	}
+	return loader, nil
+}
+
+// StartLoop reloads the CRLs whenever the files change until the context is cancelled.
+func (l *CRLLoader) StartLoop(ctx context.Context) error {
+	return l.file.StartLoop(ctx)
+}
+
+// Watch returns a channel of reload events, which is closed when the context is cancelled.
+func (l *CRLLoader) Watch(ctx context.Context) <-chan CRLReloadEvent {
+	return l.file.Watch(ctx)
+}
+
+// VerifyPeer is a PeerVerifier that rejects the peer if a certificate of its verified chain
+// is revoked by a CRL of its issuer. Intermediates are checked as well as the leaf.
+func (l *CRLLoader) VerifyPeer(state tls.ConnectionState) error {
+	crls := l.file.Load()
+	var err error
+	for _, chain := range state.VerifiedChains {
+		if err = crls.checkChain(chain); err == nil {
+			return nil
+		}
+	}
+	if err != nil {
+		leaf := state.PeerCertificates[0]
+		l.options.Logger.Warn("Rejected peer by CRL",
+			slog.String("subject", leaf.Subject.String()),
+			slog.String("server-name", state.ServerName),
+			slog.String("reason", err.Error()),
+		)
+	}
+	return err
+}
+
+// paths returns the CRL files, sorted by name.
+func (l *CRLLoader) paths() ([]string, error) {
+	info, err := os.Stat(l.options.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{l.options.Path}, nil
+	}
+	entries, err := os.ReadDir(l.options.Path)
+	if err != nil {
+		return nil, err
+	}
+	var rv []string
+	for _, entry := range entries {
+		// Skip hidden files, including the internals of the Kubernetes atomic writer.
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(l.options.Path, entry.Name())
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			rv = append(rv, path)
+		}
+	}
+	return rv, nil
+}
+
+func (l *CRLLoader) parseFiles(paths []string, contents [][]byte) (*CRLSet, error) {
+	var lists []*x509.RevocationList
+	for i, content := range contents {
+		parsed, err := parseCRLs(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", paths[i], err)
+		}
+		lists = append(lists, parsed...)
+	}
+	crls := newCRLSet(lists, l.options.AllowStale)
+
+	now := time.Now()
+	for _, entries := range crls.byIssuer {
+		if list := entries[0].RevocationList; isStaleCRL(list, now) {
+			l.options.Logger.Warn("CRL is past its next update",
+				slog.String("issuer", list.Issuer.String()),
+				slog.Time("next-update", list.NextUpdate),
+			)
+		}
+	}
+	return crls, nil
+}
+
+// parseCRLs parses the PEM encoded `X509 CRL` blocks of a file, or a single DER encoded CRL.
+func parseCRLs(data []byte) ([]*x509.RevocationList, error) {
+	if !bytes.Contains(data, []byte("-----BEGIN")) {
+		list, err := x509.ParseRevocationList(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrParseCRL, err)
+		}
+		return []*x509.RevocationList{list}, nil
+	}
+	var rv []*x509.RevocationList
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "X509 CRL" {
+			continue
+		}
+		list, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrParseCRL, err)
+		}
+		rv = append(rv, list)
+	}
+	if len(rv) == 0 {
+		return nil, fmt.Errorf("%w: no X509 CRL block found", ErrParseCRL)
+	}
+	return rv, nil
+}
+
+// CRLSet is the set of CRLs in use by a CRLLoader. It indexes the CRLs by the raw subject
+// of their issuer, newest first, so a handshake only looks at the lists of the issuers of
+// its chain. Only the newest list signed by an
+// issuer is used: older ones left next to it are superseded, and their staleness is ignored.
+type CRLSet struct {
+	byIssuer   map[string][]*crlEntry
+	count      int
+	allowStale bool
+}
+
+func newCRLSet(lists []*x509.RevocationList, allowStale bool) *CRLSet {
+	s := &CRLSet{byIssuer: make(map[string][]*crlEntry), count: len(lists), allowStale: allowStale}
+	for _, list := range lists {
+		entry := &crlEntry{RevocationList: list, revoked: make(map[string]struct{}, len(list.RevokedCertificateEntries))}
+		for _, revoked := range list.RevokedCertificateEntries {
+			entry.revoked[string(revoked.SerialNumber.Bytes())] = struct{}{}
+		}
+		s.byIssuer[string(list.RawIssuer)] = append(s.byIssuer[string(list.RawIssuer)], entry)
+	}
+	for _, entries := range s.byIssuer {
+		slices.SortStableFunc(entries, func(a, b *crlEntry) int {
+			return -compareCRLs(a.RevocationList, b.RevocationList)
+		})
+	}
+	return s
+}
+
+// compareCRLs orders CRLs of the same issuer by their CRL number, then by ThisUpdate.
+// A list without a number is older than any list with one.
+func compareCRLs(a, b *x509.RevocationList) int {
+	switch {
+	case a.Number != nil && b.Number != nil:
+		if c := a.Number.Cmp(b.Number); c != 0 {
+			return c
+		}
+	case a.Number != nil:
+		return 1
+	case b.Number != nil:
+		return -1
+	}
+	return a.ThisUpdate.Compare(b.ThisUpdate)
+}
+
+// crlEntry is a CRL with its revoked serials indexed, and the outcome of checking its
+// signature against the issuers it was matched with. The lists don't carry the certificate
+// of their issuer, so each signature is checked the first time a chain names the issuer,
+// and never again for as long as the list is loaded.
+type crlEntry struct {
+	*x509.RevocationList
+	revoked    map[string]struct{}
+	signatures sync.Map // string(issuer.Raw) -> error
+}
+
+func (e *crlEntry) signedBy(issuer *x509.Certificate) bool {
+	if err, ok := e.signatures.Load(string(issuer.Raw)); ok {
+		return err == nil
+	}
+	err, _ := e.signatures.LoadOrStore(string(issuer.Raw), e.CheckSignatureFrom(issuer))
+	return err == nil
+}
+
+func isStaleCRL(list *x509.RevocationList, now time.Time) bool {
+	return !list.NextUpdate.IsZero() && list.NextUpdate.Before(now)
+}
+
+// checkChain returns an error if any certificate of the chain is revoked by the newest CRL
+// signed by its issuer, which is the next certificate of the chain, or if that CRL is stale
+// and stale lists are not allowed. CRLs signed by anyone else are ignored.
+func (s *CRLSet) checkChain(chain []*x509.Certificate) error {
+	now := time.Now()
+	for i := 0; i+1 < len(chain); i++ {
+		cert, issuer := chain[i], chain[i+1]
+		for _, list := range s.byIssuer[string(cert.RawIssuer)] {
+			if !list.signedBy(issuer) {
+				continue
+			}
+			if !s.allowStale && isStaleCRL(list.RevocationList, now) {
+				return fmt.Errorf("%w: CRL of %s expired at %s", ErrStaleCRL, list.Issuer, list.NextUpdate.Format(time.RFC3339))
+			}
+			if _, ok := list.revoked[string(cert.SerialNumber.Bytes())]; ok {
+				return fmt.Errorf("%w: serial %s of %s", ErrCertificateRevoked, formatSerial(cert.SerialNumber), cert.Subject)
+			}
+			break // Older lists of the issuer are superseded.
+		}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package mtls
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fakeCRLSignerTemplate() *x509.Certificate {
+	return fakeCATemplate(func(template *x509.Certificate) {
+		template.KeyUsage |= x509.KeyUsageCRLSign
+	})
+}
+
+// RevocationList returns a PEM encoded CRL of the CA revoking the serials.
+func (ca *CA) RevocationList(serials ...*big.Int) []byte {
+	return ca.RevocationListUntil(time.Now().Add(time.Hour), serials...)
+}
+
+// RevocationListUntil is RevocationList with the given next update.
+func (ca *CA) RevocationListUntil(nextUpdate time.Time, serials ...*big.Int) []byte {
+	now := time.Now()
+	template := &x509.RevocationList{
+		Number:     big.NewInt(now.UnixNano()),
+		ThisUpdate: now.Add(-2 * time.Hour),
+		NextUpdate: nextUpdate,
+	}
+	for _, serial := range serials {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries,
+			x509.RevocationListEntry{SerialNumber: serial, RevocationTime: now})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, ca.Certificate, ca.PrivateKey.(crypto.Signer))
+	PanicIfErr(err)
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+}
+
+func withSerial(serial int64) func(template *x509.Certificate) {
+	return func(template *x509.Certificate) {
+		template.SerialNumber = big.NewInt(serial)
+	}
+}
+
+func TestCRLLoader_VerifyPeer(t *testing.T) {
+	t.Parallel()
+
+	const ServerName = "test-server"
+
+	var (
+		ca            = fakeCA(fakeCRLSignerTemplate())
+		otherCA       = fakeCA(fakeCRLSignerTemplate())
+		serverKeyPair = ca.Sign(fakeServerTemplate(func(template *x509.Certificate) {
+			template.DNSNames = []string{ServerName}
+			template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		}, withSerial(100)))
+		clientKeyPair = ca.Sign(fakeClientTemplate(withSerial(200)))
+	)
+
+	get := func(serverVerifier, clientVerifier PeerVerifier) error {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		server.TLS = CreateTLSConfigForServer(&fakeKeyPairLoader{keyPair: serverKeyPair}, serverVerifier)
+		server.StartTLS()
+		defer server.Close()
+
+		client := http.Client{
+			Transport: CreateDynamicTLSTransport(&fakeKeyPairLoader{keyPair: clientKeyPair}, clientVerifier),
+		}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	crlLoader := func(t *testing.T, crl []byte) *CRLLoader {
+		path := filepath.Join(t.TempDir(), "ca.crl")
+		require.NoError(t, os.WriteFile(path, crl, 0o600))
+		loader, err := NewCRLLoader(CRLLoaderOptions{FileLoaderOptions: FileLoaderOptions{Path: path}})
+		require.NoError(t, err)
+		return loader
+	}
+
+	t.Run("it should accept peers that are not revoked", func(t *testing.T) {
+		t.Parallel()
+		loader := crlLoader(t, ca.RevocationList(big.NewInt(300)))
+		require.NoError(t, get(loader.VerifyPeer, loader.VerifyPeer))
+	})
+
+	t.Run("it should reject a revoked client on the server", func(t *testing.T) {
+		t.Parallel()
+		loader := crlLoader(t, ca.RevocationList(big.NewInt(200)))
+		require.Error(t, get(loader.VerifyPeer, nil))
+	})
+
+	t.Run("it should reject a revoked server on the client", func(t *testing.T) {
+		t.Parallel()
+		loader := crlLoader(t, ca.RevocationList(big.NewInt(100)))
+		err := get(nil, loader.VerifyPeer)
+		require.ErrorIs(t, err, ErrCertificateRevoked)
+	})
+
+	t.Run("it should ignore CRLs of other issuers", func(t *testing.T) {
+		t.Parallel()
+		loader := crlLoader(t, otherCA.RevocationList(big.NewInt(100), big.NewInt(200)))
+		require.NoError(t, get(loader.VerifyPeer, loader.VerifyPeer))
+	})
+}
+
+func TestCRLLoader_StaleCRL(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca    = fakeCA(fakeCRLSignerTemplate())
+		leaf  = ca.Sign(fakeClientTemplate(withSerial(42))).Certificate.Leaf
+		path  = filepath.Join(t.TempDir(), "ca.crl")
+		state = tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{leaf},
+			VerifiedChains:   [][]*x509.Certificate{{leaf, ca.Certificate}},
+		}
+	)
+	require.NoError(t, os.WriteFile(path, ca.RevocationListUntil(time.Now().Add(-time.Hour)), 0o600))
+
+	t.Run("it should reject peers checked against a stale CRL by default", func(t *testing.T) {
+		t.Parallel()
+		loader, err := NewCRLLoader(CRLLoaderOptions{FileLoaderOptions: FileLoaderOptions{Path: path}})
+		require.NoError(t, err)
+		assert.ErrorIs(t, loader.VerifyPeer(state), ErrStaleCRL)
+	})
+
+	t.Run("it should only check the newest CRL of an issuer", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		stale := ca.RevocationListUntil(time.Now().Add(-time.Hour), big.NewInt(42))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "new.crl"), ca.RevocationList(), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "old.crl"), stale, 0o600))
+		loader, err := NewCRLLoader(CRLLoaderOptions{FileLoaderOptions: FileLoaderOptions{Path: dir}})
+		require.NoError(t, err)
+		assert.NoError(t, loader.VerifyPeer(state), "the stale list is superseded by the fresh one")
+	})
+
+	t.Run("it should keep using a stale CRL when allowed", func(t *testing.T) {
+		t.Parallel()
+		loader, err := NewCRLLoader(CRLLoaderOptions{FileLoaderOptions: FileLoaderOptions{Path: path}, AllowStale: true})
+		require.NoError(t, err)
+		assert.NoError(t, loader.VerifyPeer(state))
+	})
+}
+
+func TestCRLSet_CheckChain(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca      = fakeCA(fakeCRLSignerTemplate())
+		leaf    = ca.Sign(fakeClientTemplate(withSerial(42))).Certificate.Leaf
+		block   = func(crl []byte) []byte { b, _ := pem.Decode(crl); return b.Bytes }
+		list, _ = x509.ParseRevocationList(block(ca.RevocationList(big.NewInt(42))))
+		crls    = newCRLSet([]*x509.RevocationList{list}, false)
+	)
+	require.ErrorIs(t, crls.checkChain([]*x509.Certificate{leaf, ca.Certificate}), ErrCertificateRevoked)
+
+	t.Log("the signature of a list is checked once per issuer")
+	entries := crls.byIssuer[string(ca.Certificate.RawSubject)]
+	require.Len(t, entries, 1)
+	entries[0].signatures.Store(string(ca.Certificate.Raw), errors.New("not signed"))
+	assert.NoError(t, crls.checkChain([]*x509.Certificate{leaf, ca.Certificate}))
+}
+
+func TestCRLLoader_StartLoop(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca      = fakeCA(fakeCRLSignerTemplate())
+		otherCA = fakeCA(fakeCRLSignerTemplate())
+		leaf    = ca.Sign(fakeClientTemplate(withSerial(42))).Certificate.Leaf
+		dir     = t.TempDir()
+		state   = func() tls.ConnectionState {
+			return tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{leaf},
+				VerifiedChains:   [][]*x509.Certificate{{leaf, ca.Certificate}},
+			}
+		}
+	)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.crl"), otherCA.RevocationList(big.NewInt(42)), 0o600))
+
+	loader, err := NewCRLLoader(CRLLoaderOptions{FileLoaderOptions: FileLoaderOptions{
+		Path:           dir,
+		ReloadInterval: 50 * time.Millisecond,
+		WatchDebounce:  10 * time.Millisecond,
+	}})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = loader.StartLoop(ctx) }()
+	require.NoError(t, loader.VerifyPeer(state()))
+
+	t.Log("a CRL added to the directory is picked up")
+	der, _ := pem.Decode(ca.RevocationList(big.NewInt(42)))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.crl"), der.Bytes, 0o600))
+	assert.Eventually(t, func() bool {
+		return errors.Is(loader.VerifyPeer(state()), ErrCertificateRevoked)
+	}, 2*time.Second, 20*time.Millisecond)
+
+	t.Log("a malformed CRL is ignored and the previous CRLs stay in use")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.crl"), []byte("garbage"), 0o600))
+	time.Sleep(200 * time.Millisecond)
+	assert.ErrorIs(t, loader.VerifyPeer(state()), ErrCertificateRevoked)
+
+	t.Log("the peer is accepted again once the CRL is removed")
+	require.NoError(t, os.Remove(filepath.Join(dir, "broken.crl")))
+	require.NoError(t, os.Remove(filepath.Join(dir, "ca.crl")))
+	assert.Eventually(t, func() bool {
+		return loader.VerifyPeer(state()) == nil
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestParseCRLs(t *testing.T) {
+	t.Parallel()
+
+	t.Run("it should reject files without CRLs", func(t *testing.T) {
+		t.Parallel()
+		_, err := parseCRLs([]byte("garbage"))
+		require.ErrorIs(t, err, ErrParseCRL)
+		_, err = parseCRLs(ToCertificatePEM(fakeCA(fakeCATemplate()).Certificate.Raw))
+		require.ErrorIs(t, err, ErrParseCRL)
+	})
+}
@@ -0,0 +1,151 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var ErrCryptoPolicy = errors.New("crypto policy violation")
+
+// CryptoPolicy restricts the keys, signatures and lifetimes of certificates.
+type CryptoPolicy struct {
+	MinRSAKeySize       int              // Minimum RSA modulus size in bits; RSA keys are rejected if zero
+	ECDSACurves         []elliptic.Curve // Allowed ECDSA curves; ECDSA keys are rejected if empty
+	AllowEd25519        bool             // Allow Ed25519 keys
+	AllowSHA1Signatures bool             // Allow certificates signed with SHA-1; MD5 signatures are always rejected
+	MaxLeafLifetime     time.Duration    // Maximum time between NotBefore and NotAfter of the leaf; unlimited if zero
+}
+
+// DefaultCryptoPolicy returns the fleet-wide policy: RSA keys of at least 3072 bits,
+// ECDSA keys on P-256 or P-384, Ed25519 keys, no SHA-1 signatures and leaves valid
+// for at most 90 days.
+func DefaultCryptoPolicy() CryptoPolicy {
+	return CryptoPolicy{
+		MinRSAKeySize:   3072,
+		ECDSACurves:     []elliptic.Curve{elliptic.P256(), elliptic.P384()},
+		AllowEd25519:    true,
+		MaxLeafLifetime: 90 * 24 * time.Hour,
+	}
+}
+
+// ValidateKeyPair checks the leaf and the chain presented to peers against the policy.
+// It can be chained with the Validate option of the loaders, which is what the
+// CryptoPolicy option does for a custom Validate.
+func (p *CryptoPolicy) ValidateKeyPair(keyPair *TLSKeyPair) error {
+	certs := []*x509.Certificate{keyPair.Certificate.Leaf}
+	for _, der := range keyPair.Certificate.Certificate[1:] {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return fmt.Errorf("parse certificate chain: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	return p.checkChain(certs)
+}
+
+// Check returns the policy as a ValidationCheck for ComposeValidators.
+func (p *CryptoPolicy) Check() ValidationCheck {
+	return ValidationCheck{Name: "crypto-policy", Check: p.ValidateKeyPair}
+}
+
+// chainCryptoPolicy returns validate followed by the policy, if any.
+func chainCryptoPolicy(validate func(keyPair *TLSKeyPair) error, policy *CryptoPolicy) func(keyPair *TLSKeyPair) error {
+	if policy == nil {
+		return validate
+	}
+	policyCopy := *policy
+	return func(keyPair *TLSKeyPair) error {
+		if err := validate(keyPair); err != nil {
+			return err
+		}
+		return policyCopy.ValidateKeyPair(keyPair)
+	}
+}
+
+// checkChain checks the leaf followed by its issuers. The signature of a self-signed root
+// is not checked since its trust doesn't come from it.
+func (p *CryptoPolicy) checkChain(chain []*x509.Certificate) error {
+	var errs []error
+	for i, cert := range chain {
+		errs = append(errs, p.checkPublicKey(cert))
+		if !isSelfSigned(cert) {
+			errs = append(errs, p.checkSignature(cert))
+		}
+		if i == 0 {
+			errs = append(errs, p.checkLifetime(cert))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *CryptoPolicy) checkPublicKey(cert *x509.Certificate) error {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if p.MinRSAKeySize == 0 {
+			return violation(cert, "RSA keys are not allowed")
+		}
+		if size := key.N.BitLen(); size < p.MinRSAKeySize {
+			return violation(cert, "%d-bit RSA key is shorter than %d bits", size, p.MinRSAKeySize)
+		}
+	case *ecdsa.PublicKey:
+		if !slices.Contains(p.ECDSACurves, key.Curve) {
+			return violation(cert, "ECDSA curve %s is not allowed", key.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		if !p.AllowEd25519 {
+			return violation(cert, "Ed25519 keys are not allowed")
+		}
+	default:
+		return violation(cert, "%s keys are not allowed", cert.PublicKeyAlgorithm)
+	}
+	return nil
+}
+
+func (p *CryptoPolicy) checkSignature(cert *x509.Certificate) error {
+	switch cert.SignatureAlgorithm {
+	case x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+		if !p.AllowSHA1Signatures {
+			return violation(cert, "%s signatures are not allowed", cert.SignatureAlgorithm)
+		}
+	case x509.MD2WithRSA, x509.MD5WithRSA, x509.UnknownSignatureAlgorithm:
+		return violation(cert, "%s signatures are not allowed", cert.SignatureAlgorithm)
+	}
+	return nil
+}
+
+func (p *CryptoPolicy) checkLifetime(cert *x509.Certificate) error {
+	if p.MaxLeafLifetime == 0 {
+		return nil
+	}
+	if lifetime := cert.NotAfter.Sub(cert.NotBefore); lifetime > p.MaxLeafLifetime {
+		return violation(cert, "lifetime of %s exceeds %s", lifetime, p.MaxLeafLifetime)
+	}
+	return nil
+}
+
+func violation(cert *x509.Certificate, format string, args ...any) error {
+	return fmt.Errorf("%w: certificate %q: %s", ErrCryptoPolicy, cert.Subject, fmt.Sprintf(format, args...))
+}
+
+// VerifyPeerCryptoPolicy returns a PeerVerifier that checks the verified chains of the
+// peer, including their roots, against the policy. The peer is accepted if any of its
+// chains complies, and rejected if it has no verified chain.
+func VerifyPeerCryptoPolicy(policy CryptoPolicy) PeerVerifier {
+	return func(state tls.ConnectionState) error {
+		err := fmt.Errorf("%w: no verified chain to check", ErrCryptoPolicy)
+		for _, chain := range state.VerifiedChains {
+			if err = policy.checkChain(chain); err == nil {
+				return nil
+			}
+		}
+		return err
+	}
+}
@@ -0,0 +1,163 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCryptoPolicy_ValidateKeyPair(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca     = fakeCA(fakeCATemplate())
+		policy = DefaultCryptoPolicy()
+	)
+	rsa2048, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	require.NoError(t, err)
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	t.Run("it should accept compliant key pairs", func(t *testing.T) {
+		t.Parallel()
+
+		assert.NoError(t, policy.ValidateKeyPair(ca.Sign(fakeServerTemplate())))
+		assert.NoError(t, policy.ValidateKeyPair(ca.SignKey(fakeServerTemplate(), p256)))
+		assert.NoError(t, policy.ValidateKeyPair(ca.SignKey(fakeServerTemplate(), ed25519Key)))
+	})
+
+	t.Run("it should reject weak keys", func(t *testing.T) {
+		t.Parallel()
+
+		err := policy.ValidateKeyPair(ca.SignKey(fakeServerTemplate(), rsa2048))
+		require.ErrorIs(t, err, ErrCryptoPolicy)
+		assert.ErrorContains(t, err, "2048-bit RSA key is shorter than 3072 bits")
+
+		err = policy.ValidateKeyPair(ca.SignKey(fakeServerTemplate(), p224))
+		require.ErrorIs(t, err, ErrCryptoPolicy)
+		assert.ErrorContains(t, err, "ECDSA curve P-224 is not allowed")
+
+		noEd25519 := policy
+		noEd25519.AllowEd25519 = false
+		err = noEd25519.ValidateKeyPair(ca.SignKey(fakeServerTemplate(), ed25519Key))
+		assert.ErrorIs(t, err, ErrCryptoPolicy)
+	})
+
+	t.Run("it should reject SHA-1 signatures unless allowed", func(t *testing.T) {
+		t.Parallel()
+
+		keyPair := ca.SignKey(fakeServerTemplate(func(template *x509.Certificate) {
+			template.SignatureAlgorithm = x509.ECDSAWithSHA1
+		}), p256)
+		err := policy.ValidateKeyPair(keyPair)
+		require.ErrorIs(t, err, ErrCryptoPolicy)
+		assert.ErrorContains(t, err, "ECDSA-SHA1 signatures are not allowed")
+
+		allowSHA1 := policy
+		allowSHA1.AllowSHA1Signatures = true
+		assert.NoError(t, allowSHA1.ValidateKeyPair(keyPair))
+	})
+
+	t.Run("it should reject leaves living too long", func(t *testing.T) {
+		t.Parallel()
+
+		keyPair := ca.SignKey(fakeServerTemplate(func(template *x509.Certificate) {
+			template.NotAfter = template.NotBefore.Add(365 * 24 * time.Hour)
+		}), p256)
+		err := policy.ValidateKeyPair(keyPair)
+		require.ErrorIs(t, err, ErrCryptoPolicy)
+		assert.ErrorContains(t, err, "exceeds")
+	})
+
+	t.Run("it should report every violation of the chain", func(t *testing.T) {
+		t.Parallel()
+
+		keyPair := ca.SignKey(fakeServerTemplate(func(template *x509.Certificate) {
+			template.NotAfter = template.NotBefore.Add(365 * 24 * time.Hour)
+		}), rsa2048)
+		err := policy.ValidateKeyPair(keyPair)
+		assert.ErrorContains(t, err, "2048-bit RSA key")
+		assert.ErrorContains(t, err, "exceeds")
+	})
+}
+
+func TestVerifyPeerCryptoPolicy(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca    = fakeCA(fakeCATemplate())
+		state = func(keyPair *TLSKeyPair) tls.ConnectionState {
+			return tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{keyPair.Certificate.Leaf},
+				VerifiedChains:   [][]*x509.Certificate{{keyPair.Certificate.Leaf, ca.Certificate}},
+			}
+		}
+		verify = VerifyPeerCryptoPolicy(DefaultCryptoPolicy())
+	)
+	weakKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	assert.NoError(t, verify(state(ca.Sign(fakeClientTemplate()))))
+	assert.ErrorIs(t, verify(state(ca.SignKey(fakeClientTemplate(), weakKey))), ErrCryptoPolicy)
+	assert.ErrorIs(t, verify(tls.ConnectionState{}), ErrCryptoPolicy)
+}
+
+func TestSourceTLSConfigLoader_CryptoPolicy(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca     = fakeCA(fakeCATemplate())
+		policy = DefaultCryptoPolicy()
+	)
+	weakKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	_, err = NewSourceServerTLSConfigLoader(
+		&fakeKeyPairSource{raw: ca.SignKey(fakeServerTemplate(), weakKey).Raw},
+		SourceTLSConfigLoaderOptions{CryptoPolicy: &policy},
+	)
+	require.ErrorIs(t, err, ErrValidateKeyPair)
+	assert.ErrorIs(t, err, ErrCryptoPolicy)
+
+	_, err = NewSourceServerTLSConfigLoader(
+		&fakeKeyPairSource{raw: ca.Sign(fakeServerTemplate()).Raw},
+		SourceTLSConfigLoaderOptions{CryptoPolicy: &policy},
+	)
+	assert.NoError(t, err)
+
+	t.Log("the policy is reported along with the other checks of the default validator")
+	expiring := ca.SignKey(fakeServerTemplate(func(cert *x509.Certificate) {
+		cert.NotAfter = cert.NotBefore.Add(10 * time.Minute)
+	}), weakKey)
+	_, err = NewSourceServerTLSConfigLoader(
+		&fakeKeyPairSource{raw: expiring.Raw},
+		SourceTLSConfigLoaderOptions{CryptoPolicy: &policy, MinRemainingValidity: time.Hour},
+	)
+	var report *ValidationReport
+	require.ErrorAs(t, err, &report)
+	var failed []string
+	for _, result := range report.Failed() {
+		failed = append(failed, result.Name)
+	}
+	assert.Equal(t, []string{"expiry", "crypto-policy"}, failed)
+
+	t.Log("the policy is chained after a custom validator")
+	_, err = NewSourceServerTLSConfigLoader(
+		&fakeKeyPairSource{raw: ca.SignKey(fakeServerTemplate(), weakKey).Raw},
+		SourceTLSConfigLoaderOptions{CryptoPolicy: &policy, Validate: func(*TLSKeyPair) error { return nil }},
+	)
+	assert.ErrorIs(t, err, ErrCryptoPolicy)
+}
@@ -9,12 +9,14 @@ import (
 )
 
 const (
-	GRPCStubServiceName = "StubService"
-	GRPCStubServicePing = "Ping"
+	GRPCStubServiceName       = "StubService"
+	GRPCStubServicePing       = "Ping"
+	GRPCStubServicePingStream = "PingStream"
 )
 
 type StubService interface {
 	Ping(ctx context.Context, req *emptypb.Empty) (*emptypb.Empty, error)
+	PingStream(stream grpc.ServerStream) error
 }
 
 type stubServiceImpl struct {
@@ -24,6 +26,14 @@ func (s *stubServiceImpl) Ping(ctx context.Context, req *emptypb.Empty) (*emptyp
 	return &emptypb.Empty{}, nil
 }
 
+func (s *stubServiceImpl) PingStream(stream grpc.ServerStream) error {
+	var in emptypb.Empty
+	if err := stream.RecvMsg(&in); err != nil {
+		return err
+	}
+	return stream.SendMsg(&emptypb.Empty{})
+}
+
 func RegisterStubService(server *grpc.Server) {
 	server.RegisterService(&grpc.ServiceDesc{
 		ServiceName: GRPCStubServiceName,
@@ -38,10 +48,29 @@ func RegisterStubService(server *grpc.Server) {
 					if err := dec(&in); err != nil {
 						return nil, err
 					}
-					return srv.(StubService).Ping(ctx, &in)
+					if interceptor == nil {
+						return srv.(StubService).Ping(ctx, &in)
+					}
+					info := &grpc.UnaryServerInfo{
+						Server:     srv,
+						FullMethod: fmt.Sprintf("/%s/%s", GRPCStubServiceName, GRPCStubServicePing),
+					}
+					return interceptor(ctx, &in, info, func(ctx context.Context, req any) (any, error) {
+						return srv.(StubService).Ping(ctx, req.(*emptypb.Empty))
+					})
 				},
 			},
 		},
+		Streams: []grpc.StreamDesc{
+			{
+				StreamName: GRPCStubServicePingStream,
+				Handler: func(srv any, stream grpc.ServerStream) error {
+					return srv.(StubService).PingStream(stream)
+				},
+				ClientStreams: true,
+				ServerStreams: true,
+			},
+		},
 	}, &stubServiceImpl{})
 }
 
@@ -51,3 +80,22 @@ func InvokePing(ctx context.Context, conn *grpc.ClientConn) (*emptypb.Empty, err
 	err := conn.Invoke(ctx, method, &emptypb.Empty{}, &out)
 	return &out, err
 }
+
+func InvokePingStream(ctx context.Context, conn *grpc.ClientConn) (*emptypb.Empty, error) {
+	method := fmt.Sprintf("/%s/%s", GRPCStubServiceName, GRPCStubServicePingStream)
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}, method)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(&emptypb.Empty{}); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	var out emptypb.Empty
+	if err := stream.RecvMsg(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -3,17 +3,23 @@ package mtls
 import (
 	"bytes"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"errors"
+	"io"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"sync"
 	"time"
+
+	"golang.org/x/crypto/ocsp"
 )
 
 func PanicIfErr(err error) {
@@ -54,9 +60,14 @@ func (ca *CA) pool() *x509.CertPool {
 }
 
 func (ca *CA) Sign(template *x509.Certificate) *TLSKeyPair {
-	privateKey, err := rsa.GenerateKey(rand.Reader, 4096)
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	PanicIfErr(err)
-	certBytes, err := x509.CreateCertificate(rand.Reader, template, ca.Certificate, &privateKey.PublicKey, ca.PrivateKey)
+	return ca.SignKey(template, privateKey)
+}
+
+// SignKey issues a certificate for the private key.
+func (ca *CA) SignKey(template *x509.Certificate, privateKey crypto.Signer) *TLSKeyPair {
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, ca.Certificate, privateKey.Public(), ca.PrivateKey)
 	PanicIfErr(err)
 	certPEM, pkPEM := ToCertificatePEM(certBytes), ToPrivateKeyPEM(privateKey)
 	cert, err := tls.X509KeyPair(certPEM, pkPEM)
@@ -68,8 +79,86 @@ func (ca *CA) Sign(template *x509.Certificate) *TLSKeyPair {
 	}
 }
 
+// SignIntermediate issues an intermediate CA signed by this CA.
+func (ca *CA) SignIntermediate(template *x509.Certificate) *CA {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	PanicIfErr(err)
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, ca.Certificate, &privateKey.PublicKey, ca.PrivateKey)
+	PanicIfErr(err)
+	cert, err := x509.ParseCertificate(certBytes)
+	PanicIfErr(err)
+	return &CA{
+		Certificate: cert,
+		PrivateKey:  privateKey,
+	}
+}
+
+// OCSPResponse returns a DER encoded OCSP response of the CA for the certificate.
+func (ca *CA) OCSPResponse(cert *x509.Certificate, status int, nextUpdate time.Time) []byte {
+	now := time.Now()
+	template := ocsp.Response{
+		Status:       status,
+		SerialNumber: cert.SerialNumber,
+		ThisUpdate:   now.Add(-time.Minute),
+		NextUpdate:   nextUpdate,
+	}
+	if status == ocsp.Revoked {
+		template.RevokedAt = now.Add(-time.Minute)
+	}
+	der, err := ocsp.CreateResponse(ca.Certificate, ca.Certificate, template, ca.PrivateKey.(crypto.Signer))
+	PanicIfErr(err)
+	return der
+}
+
+// fakeOCSPResponder answers OCSP requests for certificates of the CA. Certificates are
+// good unless their status is set otherwise.
+type fakeOCSPResponder struct {
+	*httptest.Server
+
+	ca       *CA
+	mu       sync.Mutex
+	statuses map[string]int
+	requests int
+}
+
+func newFakeOCSPResponder(ca *CA) *fakeOCSPResponder {
+	r := &fakeOCSPResponder{ca: ca, statuses: map[string]int{}}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	return r
+}
+
+func (r *fakeOCSPResponder) SetStatus(serial *big.Int, status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses[serial.String()] = status
+}
+
+func (r *fakeOCSPResponder) Requests() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests
+}
+
+func (r *fakeOCSPResponder) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	PanicIfErr(err)
+	request, err := ocsp.ParseRequest(body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.mu.Lock()
+	r.requests++
+	status := r.statuses[request.SerialNumber.String()] // ocsp.Good is 0
+	r.mu.Unlock()
+
+	der := r.ca.OCSPResponse(&x509.Certificate{SerialNumber: request.SerialNumber}, status, time.Now().Add(time.Hour))
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	_, _ = w.Write(der)
+}
+
 func fakeCA(template *x509.Certificate) *CA {
-	privateKey, err := rsa.GenerateKey(rand.Reader, 4096)
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	PanicIfErr(err)
 	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
 	PanicIfErr(err)
@@ -0,0 +1,187 @@
+package mtls
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FileLoaderOptions are the options shared by the loaders that keep something parsed from
+// local files up to date, e.g. PeerPolicyLoader, CRLLoader and CABundleLoader.
+type FileLoaderOptions struct {
+	Path           string        // Path to the file, or to the directory of files for CRLLoader
+	ReloadInterval time.Duration // Interval to poll the files when they can't be watched
+	WatchDebounce  time.Duration // Quiet period after a file event before reloading
+	Logger         *slog.Logger  // Logger for reloads; defaults to slog.Default()
+
+	OnError                func(error)   // Called with the error of every failed reload attempt
+	MaxConsecutiveFailures int           // StartLoop returns an error after this many failures in a row; 0 retries forever
+	RetryBackoff           time.Duration // Delay before retrying a failed reload, doubled on every failure
+	MaxRetryBackoff        time.Duration // Upper bound of the retry delay
+}
+
+func (opts *FileLoaderOptions) defaults() error {
+	if opts.ReloadInterval == 0 {
+		opts.ReloadInterval = DefaultReloadInterval
+	}
+	if opts.WatchDebounce == 0 {
+		opts.WatchDebounce = DefaultWatchDebounce
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.MaxConsecutiveFailures < 0 {
+		return fmt.Errorf("max consecutive failures must not be negative")
+	}
+	if opts.RetryBackoff == 0 {
+		opts.RetryBackoff = DefaultRetryBackoff
+	}
+	if opts.MaxRetryBackoff == 0 {
+		opts.MaxRetryBackoff = max(DefaultMaxRetryBackoff, opts.RetryBackoff)
+	}
+	return nil
+}
+
+// FileReloadEvent describes the outcome of a reload attempt of a file loader that found new
+// content, like ReloadEvent does for key pairs.
+type FileReloadEvent[T any] struct {
+	Generation uint64 // Generation of the value in use after the attempt; starts at 1
+	Previous   *T     // Previous is the value in use before the attempt
+	Current    *T     // Current is the value in use after the attempt; same as Previous on failure
+	Err        error  // Err is the reason the attempt failed; nil on success
+}
+
+// Succeeded reports whether the new value was swapped in.
+func (e FileReloadEvent[T]) Succeeded() bool {
+	return e.Err == nil
+}
+
+// fileLoader keeps a value parsed from local files up to date. Reloads are skipped while
+// the files are unchanged, and a reload that fails leaves the previous value in use.
+type fileLoader[T any] struct {
+	name    string // What is loaded, for the logs and errors, e.g. "peer policy"
+	options FileLoaderOptions
+	list    func() ([]string, error)                            // Lists the files to read; defaults to Path
+	parse   func(paths []string, contents [][]byte) (*T, error) // Parses the contents of the listed files
+	attrs   func(value *T) []slog.Attr                          // Attributes of the reload logs; optional
+
+	mu         sync.Mutex // serializes reloads
+	stamps     []fileStamp
+	checksum   []byte
+	value      atomic.Pointer[T]
+	generation atomic.Uint64
+	notifier   reloadNotifier[FileReloadEvent[T]]
+}
+
+func (l *fileLoader[T]) StartLoop(ctx context.Context) error {
+	loop := &reloadLoop{
+		name:                   l.name,
+		attrs:                  []slog.Attr{slog.String("path", l.options.Path)},
+		interval:               l.options.ReloadInterval,
+		logger:                 l.options.Logger,
+		watch:                  watchFiles([]string{l.options.Path}, l.options.WatchDebounce, l.options.ReloadInterval, l.options.Logger),
+		changed:                l.filesChanged,
+		reload:                 l.load,
+		onError:                l.options.OnError,
+		maxConsecutiveFailures: l.options.MaxConsecutiveFailures,
+		retryBackoff:           l.options.RetryBackoff,
+		maxRetryBackoff:        l.options.MaxRetryBackoff,
+	}
+	return loop.run(ctx)
+}
+
+func (l *fileLoader[T]) Watch(ctx context.Context) <-chan FileReloadEvent[T] {
+	return l.notifier.Watch(ctx)
+}
+
+func (l *fileLoader[T]) Load() *T {
+	return l.value.Load()
+}
+
+// files returns the files to stat, which include the directory of listed files, and the
+// files to read.
+func (l *fileLoader[T]) files() (stat, read []string, err error) {
+	if l.list == nil {
+		return []string{l.options.Path}, []string{l.options.Path}, nil
+	}
+	read, err = l.list()
+	if err != nil {
+		return nil, nil, err
+	}
+	return append([]string{l.options.Path}, read...), read, nil
+}
+
+func (l *fileLoader[T]) filesChanged() bool {
+	paths, _, err := l.files()
+	if err != nil {
+		return true // Let load surface the error.
+	}
+	stamps, err := statFiles(paths)
+	if err != nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return !fileStampsEqual(l.stamps, stamps)
+}
+
+func (l *fileLoader[T]) load() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	statPaths, paths, err := l.files()
+	if err != nil {
+		return l.rejectLocked(fmt.Errorf("list %s: %w", l.name, err))
+	}
+	stamps, err := statFiles(statPaths)
+	if err != nil {
+		return l.rejectLocked(fmt.Errorf("stat %s: %w", l.name, err))
+	}
+	contents, err := readFilesSnapshot(paths)
+	if err != nil {
+		return l.rejectLocked(fmt.Errorf("read %s: %w", l.name, err))
+	}
+	h := sha256.New()
+	for _, content := range contents {
+		h.Write(content)
+	}
+	checksum := h.Sum(nil)
+	if bytes.Equal(l.checksum, checksum) {
+		l.stamps = stamps
+		return nil // No changes, skip parsing.
+	}
+	value, err := l.parse(paths, contents)
+	if err != nil {
+		return l.rejectLocked(err)
+	}
+	previous := l.value.Swap(value)
+	l.stamps, l.checksum = stamps, checksum
+
+	attrs := []slog.Attr{slog.String("path", l.options.Path)}
+	if l.attrs != nil {
+		attrs = append(attrs, l.attrs(value)...)
+	}
+	l.options.Logger.LogAttrs(context.Background(), slog.LevelInfo, "Loaded "+l.name, attrs...)
+	l.notifier.notify(FileReloadEvent[T]{
+		Generation: l.generation.Add(1),
+		Previous:   previous,
+		Current:    value,
+	})
+	return nil
+}
+
+func (l *fileLoader[T]) rejectLocked(err error) error {
+	current := l.value.Load()
+	l.notifier.notify(FileReloadEvent[T]{
+		Generation: l.generation.Load(),
+		Previous:   current,
+		Current:    current,
+		Err:        err,
+	})
+	return err
+}
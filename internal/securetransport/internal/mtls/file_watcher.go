@@ -0,0 +1,214 @@
+package mtls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var DefaultWatchDebounce = 100 * time.Millisecond
+
+// watchDebounceMaxWait bounds, in debounce periods, how long a steady stream of events
+// can hold back the notification of a burst.
+const watchDebounceMaxWait = 10
+
+var errWatcherClosed = errors.New("file watcher closed")
+
+// fileWatcher watches the parent directories of a set of files and emits a
+// debounced notification whenever one of the files may have changed. The
+// notification of a burst is sent at most watchDebounceMaxWait debounce
+// periods after its first event, even if events keep coming.
+//
+// Directories are watched instead of the files themselves so that rename,
+// remove and atomic-replace (write to a temp file, then rename over the
+// target) are observed as well. This also covers the Kubernetes atomic
+// writer, which swaps a `..data` symlink next to the files. Paths that are
+// directories are watched themselves, and any file in them is relevant.
+type fileWatcher struct {
+	paths    []string
+	dirPaths []string
+	debounce time.Duration
+	retry    time.Duration
+	logger   *slog.Logger
+	notifyC  chan struct{}
+}
+
+func newFileWatcher(paths []string, debounce, retry time.Duration, logger *slog.Logger) *fileWatcher {
+	var cleaned, dirPaths []string
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			dirPaths = append(dirPaths, path)
+		} else {
+			cleaned = append(cleaned, path)
+		}
+	}
+	return &fileWatcher{
+		paths:    cleaned,
+		dirPaths: dirPaths,
+		debounce: debounce,
+		retry:    retry,
+		logger:   logger,
+		notifyC:  make(chan struct{}, 1),
+	}
+}
+
+// C returns the channel on which change notifications are delivered.
+// Notifications are coalesced, so a single receive may stand for many events.
+func (w *fileWatcher) C() <-chan struct{} {
+	return w.notifyC
+}
+
+// Run watches the files until the context is cancelled. When the watch cannot
+// be established, or breaks because a directory disappeared, it is retried
+// after the retry interval. The caller is expected to keep polling meanwhile.
+func (w *fileWatcher) Run(ctx context.Context) {
+	for {
+		err := w.watch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		w.logger.Warn("Failed to watch files, falling back to polling",
+			slog.String("error", err.Error()),
+			slog.Duration("retry-in", w.retry),
+		)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.retry):
+		}
+	}
+}
+
+func (w *fileWatcher) watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create fsnotify watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	dirs := w.dirs()
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("watch directory %s: %w", dir, err)
+		}
+	}
+	// The files may have changed while the watch was not in place.
+	w.notify()
+
+	var (
+		timer    *time.Timer
+		timerC   <-chan time.Time // Set while a burst of events is pending
+		deadline time.Time        // Latest notification of the pending burst
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return errWatcherClosed
+			}
+			if event.Has(fsnotify.Remove|fsnotify.Rename) && slices.Contains(dirs, filepath.Clean(event.Name)) {
+				return fmt.Errorf("watched directory %s is gone", event.Name)
+			}
+			if !w.isRelevant(event) {
+				continue
+			}
+			if timerC == nil {
+				deadline = time.Now().Add(watchDebounceMaxWait * w.debounce)
+			}
+			delay := min(w.debounce, time.Until(deadline))
+			if timer == nil {
+				timer = time.NewTimer(delay)
+			} else {
+				timer.Reset(delay)
+			}
+			timerC = timer.C
+		case <-timerC:
+			timerC = nil
+			w.notify()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return errWatcherClosed
+			}
+			return fmt.Errorf("fsnotify watcher: %w", err)
+		}
+	}
+}
+
+func (w *fileWatcher) notify() {
+	select {
+	case w.notifyC <- struct{}{}:
+	default: // A notification is already pending.
+	}
+}
+
+func (w *fileWatcher) dirs() []string {
+	rv := slices.Clone(w.dirPaths)
+	for _, path := range w.paths {
+		dir := filepath.Dir(path)
+		if !slices.Contains(rv, dir) {
+			rv = append(rv, dir)
+		}
+	}
+	return rv
+}
+
+func (w *fileWatcher) isRelevant(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	name := filepath.Clean(event.Name)
+	if slices.Contains(w.paths, name) || slices.Contains(w.dirPaths, filepath.Dir(name)) {
+		return true
+	}
+	// Kubernetes atomic writer internals, e.g. `..data` and `..2006_01_02_15_04_05.000000000`.
+	return strings.HasPrefix(filepath.Base(name), "..")
+}
+
+// fileStamp is a cheap fingerprint of a file used to skip re-reading
+// files that have not changed since they were last loaded.
+type fileStamp struct {
+	info os.FileInfo
+}
+
+func statFiles(paths []string) ([]fileStamp, error) {
+	rv := make([]fileStamp, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path) // follow symlinks
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, fileStamp{info: info})
+	}
+	return rv, nil
+}
+
+func fileStampsEqual(a, b []fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !os.SameFile(a[i].info, b[i].info) ||
+			a[i].info.Size() != b[i].info.Size() ||
+			!a[i].info.ModTime().Equal(b[i].info.ModTime()) {
+			return false
+		}
+	}
+	return true
+}
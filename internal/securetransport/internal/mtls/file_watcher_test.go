@@ -0,0 +1,202 @@
+package mtls
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileWatcher(t *testing.T) {
+	t.Parallel()
+
+	const (
+		Debounce = 50 * time.Millisecond
+		Retry    = 100 * time.Millisecond
+		Timeout  = 2 * time.Second
+	)
+
+	startWatcher := func(t *testing.T, paths ...string) *fileWatcher {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		watcher := newFileWatcher(paths, Debounce, Retry, slog.Default())
+		go watcher.Run(ctx)
+
+		// Drain the notification emitted once the watch is established.
+		select {
+		case <-watcher.C():
+		case <-time.After(Timeout):
+			t.Fatal("watcher did not start")
+		}
+		return watcher
+	}
+
+	expectNotification := func(t *testing.T, watcher *fileWatcher) {
+		select {
+		case <-watcher.C():
+		case <-time.After(Timeout):
+			t.Fatal("expected a notification")
+		}
+	}
+
+	expectNoNotification := func(t *testing.T, watcher *fileWatcher, wait time.Duration) {
+		select {
+		case <-watcher.C():
+			t.Fatal("unexpected notification")
+		case <-time.After(wait):
+		}
+	}
+
+	t.Run("it should notify when the file is written", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		path := filepath.Join(dir, "tls.crt")
+		require.NoError(t, os.WriteFile(path, []byte("first"), 0600))
+
+		watcher := startWatcher(t, path)
+		require.NoError(t, os.WriteFile(path, []byte("second"), 0600))
+		expectNotification(t, watcher)
+	})
+
+	t.Run("it should notify when a file is added to a watched directory", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		watcher := startWatcher(t, dir)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.crl"), []byte("crl"), 0600))
+		expectNotification(t, watcher)
+	})
+
+	t.Run("it should notify when the file is atomically replaced", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		path := filepath.Join(dir, "tls.crt")
+		require.NoError(t, os.WriteFile(path, []byte("first"), 0600))
+
+		watcher := startWatcher(t, path)
+		tmp := filepath.Join(dir, "tls.crt.tmp")
+		require.NoError(t, os.WriteFile(tmp, []byte("second"), 0600))
+		require.NoError(t, os.Rename(tmp, path))
+		expectNotification(t, watcher)
+	})
+
+	t.Run("it should notify when the kubernetes data symlink is swapped", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "..v1"), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "..v1", "tls.crt"), []byte("first"), 0600))
+		require.NoError(t, os.Symlink("..v1", filepath.Join(dir, "..data")))
+		require.NoError(t, os.Symlink(filepath.Join("..data", "tls.crt"), filepath.Join(dir, "tls.crt")))
+
+		watcher := startWatcher(t, filepath.Join(dir, "tls.crt"))
+
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "..v2"), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "..v2", "tls.crt"), []byte("second"), 0600))
+		require.NoError(t, os.Symlink("..v2", filepath.Join(dir, "..data_tmp")))
+		require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+		expectNotification(t, watcher)
+	})
+
+	t.Run("it should ignore unrelated files", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		path := filepath.Join(dir, "tls.crt")
+		require.NoError(t, os.WriteFile(path, []byte("first"), 0600))
+
+		watcher := startWatcher(t, path)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "unrelated"), []byte("noise"), 0600))
+		expectNoNotification(t, watcher, 5*Debounce)
+	})
+
+	t.Run("it should debounce bursts of events", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		path := filepath.Join(dir, "tls.crt")
+		require.NoError(t, os.WriteFile(path, []byte("first"), 0600))
+
+		watcher := startWatcher(t, path)
+		for range 10 {
+			require.NoError(t, os.WriteFile(path, []byte("burst"), 0600))
+		}
+		expectNotification(t, watcher)
+		expectNoNotification(t, watcher, 5*Debounce)
+	})
+
+	t.Run("it should notify during a steady stream of events", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		path := filepath.Join(dir, "tls.crt")
+		require.NoError(t, os.WriteFile(path, []byte("first"), 0600))
+
+		watcher := startWatcher(t, path)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			ticker := time.NewTicker(Debounce / 5)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					_ = os.WriteFile(path, []byte("stream"), 0600)
+				}
+			}
+		}()
+		expectNotification(t, watcher)
+	})
+
+	t.Run("it should keep retrying when the directory does not exist", func(t *testing.T) {
+		t.Parallel()
+
+		dir := filepath.Join(t.TempDir(), "certs")
+		path := filepath.Join(dir, "tls.crt")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		watcher := newFileWatcher([]string{path}, Debounce, Retry, slog.Default())
+		go watcher.Run(ctx)
+		expectNoNotification(t, watcher, 2*Retry)
+
+		require.NoError(t, os.Mkdir(dir, 0700))
+		expectNotification(t, watcher) // watch established
+		require.NoError(t, os.WriteFile(path, []byte("first"), 0600))
+		expectNotification(t, watcher)
+	})
+}
+
+func TestFileStampsEqual(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tls.crt")
+	require.NoError(t, os.WriteFile(path, []byte("first"), 0600))
+
+	before, err := statFiles([]string{path})
+	require.NoError(t, err)
+	again, err := statFiles([]string{path})
+	require.NoError(t, err)
+	assert.True(t, fileStampsEqual(before, again))
+
+	tmp := filepath.Join(dir, "tls.crt.tmp")
+	require.NoError(t, os.WriteFile(tmp, []byte("second"), 0600))
+	require.NoError(t, os.Rename(tmp, path))
+
+	after, err := statFiles([]string{path})
+	require.NoError(t, err)
+	assert.False(t, fileStampsEqual(before, after))
+	assert.False(t, fileStampsEqual(nil, after))
+}
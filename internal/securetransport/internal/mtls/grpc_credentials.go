@@ -13,6 +13,7 @@ var _ credentials.TransportCredentials = (*dynamicTLSCredentials)(nil)
 
 type dynamicTLSCredentials struct {
 	loader interface{ KeyPair() *TLSKeyPair }
+	verify func(state tls.ConnectionState) error
 	inner  atomic.Pointer[credentialsWithKeyPair]
 }
 
@@ -21,8 +22,13 @@ type credentialsWithKeyPair struct {
 	KeyPair     *TLSKeyPair
 }
 
-func CreateDynamicTLSCredentials(loader interface{ KeyPair() *TLSKeyPair }) credentials.TransportCredentials {
-	return &dynamicTLSCredentials{loader: loader}
+// CreateDynamicTLSCredentials returns gRPC credentials that present the certificate of the
+// loader, and check the peers with the verifiers.
+func CreateDynamicTLSCredentials(
+	loader interface{ KeyPair() *TLSKeyPair },
+	verifiers ...PeerVerifier,
+) credentials.TransportCredentials {
+	return &dynamicTLSCredentials{loader: loader, verify: verifyConnection(verifiers)}
 }
 
 func (d *dynamicTLSCredentials) ClientHandshake(
@@ -55,8 +61,9 @@ func (d *dynamicTLSCredentials) innerCredentials() credentials.TransportCredenti
 	}
 	// Create new credentials.
 	cred := credentials.NewTLS(&tls.Config{
-		RootCAs:      nextKeyPair.CAs,
-		Certificates: []tls.Certificate{*nextKeyPair.Certificate},
+		RootCAs:          nextKeyPair.CAs,
+		Certificates:     []tls.Certificate{*nextKeyPair.Certificate},
+		VerifyConnection: d.verify,
 	})
 	d.inner.Store(&credentialsWithKeyPair{
 		Credentials: cred,
@@ -0,0 +1,84 @@
+package mtls
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/zarvd/mtls-demo/internal/securetransport/internal/mtls/fake"
+)
+
+func TestGRPCServerCredentials(t *testing.T) {
+	t.Parallel()
+
+	const ServerName = "test-server"
+
+	var (
+		ca            = fakeCA(fakeCATemplate())
+		serverKeyPair = func(commonName string) *TLSKeyPair {
+			return ca.Sign(fakeServerTemplate(func(template *x509.Certificate) {
+				template.Subject.CommonName = commonName
+				template.DNSNames = []string{ServerName}
+			}))
+		}
+		clientKeyPair = ca.Sign(fakeClientTemplate(func(template *x509.Certificate) {
+			template.Subject.CommonName = "test-client"
+		}))
+	)
+
+	loader, err := NewStaticServerTLSConfigLoader(serverKeyPair("first"))
+	require.NoError(t, err)
+	creds := loader.GRPCServerCredentials()
+	assert.Equal(t, "tls", creds.Info().SecurityProtocol)
+
+	var clients = make(chan string, 1)
+	server := grpc.NewServer(
+		grpc.Creds(creds),
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			p, _ := peer.FromContext(ctx)
+			tlsInfo := p.AuthInfo.(credentials.TLSInfo)
+			assert.Equal(t, credentials.PrivacyAndIntegrity, tlsInfo.SecurityLevel)
+			clients <- tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
+			return handler(ctx, req)
+		}),
+	)
+	fake.RegisterStubService(server)
+	lis := bufconn.Listen(1024 * 1024)
+	defer lis.Close()
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	// ping dials a new connection, and returns the common name of the server certificate.
+	ping := func() string {
+		conn, err := grpc.NewClient(
+			fmt.Sprintf("passthrough:%s", ServerName),
+			grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+				return lis.Dial()
+			}),
+			grpc.WithTransportCredentials(CreateDynamicTLSCredentials(&fakeKeyPairLoader{keyPair: clientKeyPair})),
+		)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		var p peer.Peer
+		require.NoError(t, conn.Invoke(context.Background(), fmt.Sprintf("/%s/%s", fake.GRPCStubServiceName, fake.GRPCStubServicePing), &emptypb.Empty{}, &emptypb.Empty{}, grpc.Peer(&p)))
+		assert.Equal(t, "test-client", <-clients)
+		return p.AuthInfo.(credentials.TLSInfo).State.PeerCertificates[0].Subject.CommonName
+	}
+
+	assert.Equal(t, "first", ping())
+
+	t.Log("new handshakes use the rotated key pair")
+	require.NoError(t, loader.Set(serverKeyPair("second")))
+	assert.Equal(t, "second", ping())
+}
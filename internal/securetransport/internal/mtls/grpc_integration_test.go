@@ -10,7 +10,6 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/test/bufconn"
 
 	"github.com/zarvd/mtls-demo/internal/securetransport/internal/mtls/fake"
@@ -86,7 +85,7 @@ func TestIntegrationGRPC(t *testing.T) {
 		defer lis.Close()
 
 		server := grpc.NewServer(
-			grpc.Creds(credentials.NewTLS(serverLoader.ServerTLSConfig())),
+			grpc.Creds(serverLoader.GRPCServerCredentials()),
 		)
 		fake.RegisterStubService(server)
 
@@ -164,7 +163,7 @@ func TestIntegrationGRPC(t *testing.T) {
 		defer lis.Close()
 
 		server := grpc.NewServer(
-			grpc.Creds(credentials.NewTLS(serverLoader.ServerTLSConfig())),
+			grpc.Creds(serverLoader.GRPCServerCredentials()),
 		)
 		fake.RegisterStubService(server)
 
@@ -233,7 +232,7 @@ func TestIntegrationGRPC(t *testing.T) {
 		defer lis.Close()
 
 		serverOptions := []grpc.ServerOption{
-			grpc.Creds(credentials.NewTLS(serverLoader.ServerTLSConfig())),
+			grpc.Creds(serverLoader.GRPCServerCredentials()),
 		}
 		server := grpc.NewServer(serverOptions...)
 		fake.RegisterStubService(server)
@@ -0,0 +1,170 @@
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type GRPCAuthorizerOptions struct {
+	Methods       map[string][]PeerVerifier // Verifiers the callers of each method must pass, by full method name, e.g. /pkg.Service/Method, or /pkg.Service/* for every method of a service
+	Public        []string                  // Full method names callable without a client certificate, e.g. /grpc.health.v1.Health/Check
+	AllowUnlisted bool                      // Let any verified client call the methods missing from Methods; they are denied by default
+	Logger        *slog.Logger              // Logger for denied calls; defaults to slog.Default()
+}
+
+func (opts *GRPCAuthorizerOptions) defaults() error {
+	for method := range opts.Methods {
+		if err := checkGRPCMethod(method, true); err != nil {
+			return err
+		}
+	}
+	for _, method := range opts.Public {
+		if err := checkGRPCMethod(method, false); err != nil {
+			return err
+		}
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return nil
+}
+
+func checkGRPCMethod(method string, wildcard bool) error {
+	service, name, ok := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	switch {
+	case !strings.HasPrefix(method, "/") || !ok || service == "" || name == "" || strings.Contains(name, "/"):
+		return fmt.Errorf("invalid gRPC method %q, expected /<service>/<method>", method)
+	case name == "*" && !wildcard:
+		return fmt.Errorf("invalid gRPC method %q, wildcards are not allowed", method)
+	}
+	return nil
+}
+
+// GRPCAuthorizer authorizes the calls to a gRPC server by the verified certificate of the
+// client, and puts the PeerIdentity of the client in the context of the handlers.
+//
+// Calls without a verified client certificate fail with codes.Unauthenticated, unless the
+// method is public. Calls by clients rejected by the verifiers of the method, or to methods
+// that are not listed, fail with codes.PermissionDenied.
+type GRPCAuthorizer struct {
+	methods       map[string]func(state tls.ConnectionState) error
+	public        map[string]bool
+	allowUnlisted bool
+	logger        *slog.Logger
+}
+
+func NewGRPCAuthorizer(options GRPCAuthorizerOptions) (*GRPCAuthorizer, error) {
+	if err := options.defaults(); err != nil {
+		return nil, err
+	}
+	rv := &GRPCAuthorizer{
+		methods:       make(map[string]func(state tls.ConnectionState) error, len(options.Methods)),
+		public:        make(map[string]bool, len(options.Public)),
+		allowUnlisted: options.AllowUnlisted,
+		logger:        options.Logger,
+	}
+	for method, verifiers := range options.Methods {
+		verify := verifyConnection(verifiers)
+		if verify == nil {
+			verify = func(tls.ConnectionState) error { return nil } // Any verified client.
+		}
+		rv.methods[method] = verify
+	}
+	for _, method := range options.Public {
+		rv.public[method] = true
+	}
+	return rv, nil
+}
+
+// UnaryServerInterceptor returns the interceptor to pass to grpc.ChainUnaryInterceptor.
+func (a *GRPCAuthorizer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := a.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns the interceptor to pass to grpc.ChainStreamInterceptor.
+func (a *GRPCAuthorizer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authorize(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStreamWithContext{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// authorize returns the context of the handler, with the identity of the client if any,
+// or the status error of the call.
+func (a *GRPCAuthorizer) authorize(ctx context.Context, method string) (context.Context, error) {
+	state, id, ok := grpcPeerIdentity(ctx)
+	if ok {
+		ctx = ContextWithPeerIdentity(ctx, id)
+	}
+	if a.public[method] {
+		return ctx, nil
+	}
+	if !ok {
+		a.logger.Info("Denied unauthenticated gRPC call", slog.String("method", method))
+		return nil, status.Error(codes.Unauthenticated, "a verified client certificate is required")
+	}
+
+	verify, listed := a.methods[method]
+	if !listed {
+		verify, listed = a.methods[path.Dir(method)+"/*"]
+	}
+	var err error
+	switch {
+	case listed:
+		err = verify(state)
+	case !a.allowUnlisted:
+		err = fmt.Errorf("method is not listed")
+	}
+	if err != nil {
+		a.logger.Info("Denied gRPC call",
+			slog.String("method", method),
+			slog.Any("peer", id),
+			slog.String("error", err.Error()),
+		)
+		return nil, status.Errorf(codes.PermissionDenied, "client %q may not call %s", id.CommonName, method)
+	}
+	return ctx, nil
+}
+
+// grpcPeerIdentity returns the connection state and identity of the client of a call, if it
+// presented a verified certificate.
+func grpcPeerIdentity(ctx context.Context) (tls.ConnectionState, *PeerIdentity, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return tls.ConnectionState{}, nil, false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return tls.ConnectionState{}, nil, false
+	}
+	id, ok := PeerIdentityFromConnectionState(info.State)
+	return info.State, id, ok
+}
+
+type serverStreamWithContext struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStreamWithContext) Context() context.Context {
+	return s.ctx
+}
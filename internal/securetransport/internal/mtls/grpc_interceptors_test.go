@@ -0,0 +1,164 @@
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/zarvd/mtls-demo/internal/securetransport/internal/mtls/fake"
+)
+
+func TestGRPCAuthorizer(t *testing.T) {
+	t.Parallel()
+
+	const ServerName = "test-server"
+
+	var (
+		ca            = fakeCA(fakeCATemplate())
+		serverKeyPair = ca.Sign(fakeServerTemplate(func(template *x509.Certificate) {
+			template.DNSNames = []string{ServerName}
+		}))
+		clientKeyPair = ca.Sign(fakeClientTemplate(func(template *x509.Certificate) {
+			template.Subject.CommonName = "test-client"
+		}))
+		opsKeyPair = ca.Sign(fakeClientTemplate(func(template *x509.Certificate) {
+			template.Subject.CommonName = "ops"
+		}))
+		onlyOps = func(state tls.ConnectionState) error {
+			if cn := state.PeerCertificates[0].Subject.CommonName; cn != "ops" {
+				return fmt.Errorf("%q is not ops", cn)
+			}
+			return nil
+		}
+		ping       = fmt.Sprintf("/%s/%s", fake.GRPCStubServiceName, fake.GRPCStubServicePing)
+		pingStream = fmt.Sprintf("/%s/%s", fake.GRPCStubServiceName, fake.GRPCStubServicePingStream)
+	)
+
+	authorizer, err := NewGRPCAuthorizer(GRPCAuthorizerOptions{
+		Methods: map[string][]PeerVerifier{
+			ping:       nil,
+			pingStream: {onlyOps},
+		},
+	})
+	require.NoError(t, err)
+
+	t.Run("it should authorize the calls by method and identity", func(t *testing.T) {
+		t.Parallel()
+
+		var identities = make(chan string, 1)
+		server := grpc.NewServer(
+			grpc.Creds(credentials.NewTLS(createTLSConfigForServerLoader(&fakeKeyPairLoader{keyPair: serverKeyPair}, VerifyClientCertIfGiven))),
+			grpc.ChainUnaryInterceptor(authorizer.UnaryServerInterceptor(), func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				id, _ := PeerIdentityFromContext(ctx)
+				identities <- id.CommonName
+				return handler(ctx, req)
+			}),
+			grpc.ChainStreamInterceptor(authorizer.StreamServerInterceptor()),
+		)
+		fake.RegisterStubService(server)
+		lis := bufconn.Listen(1024 * 1024)
+		defer lis.Close()
+		go func() { _ = server.Serve(lis) }()
+		defer server.Stop()
+
+		dial := func(creds credentials.TransportCredentials) *grpc.ClientConn {
+			conn, err := grpc.NewClient(
+				fmt.Sprintf("passthrough:%s", ServerName),
+				grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+					return lis.Dial()
+				}),
+				grpc.WithTransportCredentials(creds),
+			)
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = conn.Close() })
+			return conn
+		}
+		var (
+			anonymous = dial(credentials.NewTLS(&tls.Config{RootCAs: ca.pool()}))
+			client    = dial(CreateDynamicTLSCredentials(&fakeKeyPairLoader{keyPair: clientKeyPair}))
+			ops       = dial(CreateDynamicTLSCredentials(&fakeKeyPairLoader{keyPair: opsKeyPair}))
+			ctx       = context.Background()
+		)
+
+		_, err := fake.InvokePing(ctx, anonymous)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		_, err = fake.InvokePing(ctx, client)
+		require.NoError(t, err)
+		assert.Equal(t, "test-client", <-identities)
+
+		_, err = fake.InvokePingStream(ctx, client)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		_, err = fake.InvokePingStream(ctx, ops)
+		assert.NoError(t, err)
+	})
+
+	t.Run("it should apply the public, wildcard and unlisted methods", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			anonymous = peer.NewContext(context.Background(), &peer.Peer{})
+			client    = peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{clientKeyPair.Certificate.Leaf},
+				VerifiedChains:   [][]*x509.Certificate{{clientKeyPair.Certificate.Leaf, ca.Certificate}},
+			}}})
+		)
+		authorizer, err := NewGRPCAuthorizer(GRPCAuthorizerOptions{
+			Methods: map[string][]PeerVerifier{
+				"/pkg.Admin/*":      {onlyOps},
+				"/pkg.Admin/Whoami": nil,
+			},
+			Public: []string{"/grpc.health.v1.Health/Check"},
+		})
+		require.NoError(t, err)
+
+		tests := []struct {
+			Ctx      context.Context
+			Method   string
+			Expected codes.Code
+		}{
+			{Ctx: anonymous, Method: "/grpc.health.v1.Health/Check", Expected: codes.OK},
+			{Ctx: client, Method: "/grpc.health.v1.Health/Check", Expected: codes.OK},
+			{Ctx: anonymous, Method: "/pkg.Admin/Whoami", Expected: codes.Unauthenticated},
+			{Ctx: client, Method: "/pkg.Admin/Whoami", Expected: codes.OK},
+			{Ctx: client, Method: "/pkg.Admin/Reset", Expected: codes.PermissionDenied},
+			{Ctx: client, Method: "/pkg.Other/Get", Expected: codes.PermissionDenied},
+		}
+		for _, tt := range tests {
+			_, err := authorizer.authorize(tt.Ctx, tt.Method)
+			assert.Equal(t, tt.Expected, status.Code(err), tt.Method)
+		}
+
+		authorizer, err = NewGRPCAuthorizer(GRPCAuthorizerOptions{AllowUnlisted: true})
+		require.NoError(t, err)
+		ctx, err := authorizer.authorize(client, "/pkg.Other/Get")
+		require.NoError(t, err)
+		id, ok := PeerIdentityFromContext(ctx)
+		require.True(t, ok)
+		assert.Equal(t, "test-client", id.CommonName)
+	})
+
+	t.Run("it should reject invalid method names", func(t *testing.T) {
+		t.Parallel()
+
+		for _, method := range []string{"", "pkg.Service/Method", "/pkg.Service", "/pkg.Service/", "/a/b/c"} {
+			_, err := NewGRPCAuthorizer(GRPCAuthorizerOptions{Methods: map[string][]PeerVerifier{method: nil}})
+			assert.Error(t, err, "method %q", method)
+		}
+		_, err := NewGRPCAuthorizer(GRPCAuthorizerOptions{Public: []string{"/pkg.Service/*"}})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,29 @@
+package mtls
+
+import (
+	"net/http"
+)
+
+// RequirePeerCertificate returns a middleware for servers that don't require client
+// certificates in the handshake, e.g. with VerifyClientCertIfGiven. Requests are rejected
+// with 401 Unauthorized unless the client presented a verified certificate, and with
+// 403 Forbidden if any of the verifiers rejects it, e.g. VerifyPeerSPIFFEID or
+// PeerPolicyLoader.VerifyPeer.
+func RequirePeerCertificate(verifiers ...PeerVerifier) func(http.Handler) http.Handler {
+	verify := verifyConnection(verifiers)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if verify != nil {
+				if err := verify(*r.TLS); err != nil {
+					http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
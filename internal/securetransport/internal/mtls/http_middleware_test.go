@@ -0,0 +1,80 @@
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequirePeerCertificate(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca            = fakeCA(fakeCATemplate())
+		serverKeyPair = ca.Sign(fakeServerTemplate(func(template *x509.Certificate) {
+			template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		}))
+		clientKeyPair = ca.Sign(fakeClientTemplate(func(template *x509.Certificate) {
+			template.Subject.CommonName = "test-client"
+		}))
+		opsKeyPair = ca.Sign(fakeClientTemplate(func(template *x509.Certificate) {
+			template.Subject.CommonName = "ops"
+		}))
+		onlyOps = func(state tls.ConnectionState) error {
+			if cn := state.PeerCertificates[0].Subject.CommonName; cn != "ops" {
+				return fmt.Errorf("%q is not ops", cn)
+			}
+			return nil
+		}
+	)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", ok)
+	mux.Handle("/ping", RequirePeerCertificate()(ok))
+	mux.Handle("/admin", RequirePeerCertificate(onlyOps)(ok))
+
+	server := httptest.NewUnstartedServer(mux)
+	server.TLS = createTLSConfigForServerLoader(&fakeKeyPairLoader{keyPair: serverKeyPair}, VerifyClientCertIfGiven)
+	server.StartTLS()
+	defer server.Close()
+
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: ca.pool()}}}
+	client := &http.Client{Transport: CreateDynamicTLSTransport(&fakeKeyPairLoader{keyPair: clientKeyPair})}
+	ops := &http.Client{Transport: CreateDynamicTLSTransport(&fakeKeyPairLoader{keyPair: opsKeyPair})}
+
+	tests := []struct {
+		Name     string
+		Client   *http.Client
+		Path     string
+		Expected int
+	}{
+		{Name: "anonymous public", Client: anonymous, Path: "/healthz", Expected: http.StatusOK},
+		{Name: "anonymous protected", Client: anonymous, Path: "/ping", Expected: http.StatusUnauthorized},
+		{Name: "anonymous restricted", Client: anonymous, Path: "/admin", Expected: http.StatusUnauthorized},
+		{Name: "client public", Client: client, Path: "/healthz", Expected: http.StatusOK},
+		{Name: "client protected", Client: client, Path: "/ping", Expected: http.StatusOK},
+		{Name: "client restricted", Client: client, Path: "/admin", Expected: http.StatusForbidden},
+		{Name: "ops restricted", Client: ops, Path: "/admin", Expected: http.StatusOK},
+	}
+	for _, tt := range tests {
+		resp, err := tt.Client.Get(server.URL + tt.Path)
+		require.NoError(t, err, tt.Name)
+		require.NoError(t, resp.Body.Close())
+		assert.Equal(t, tt.Expected, resp.StatusCode, tt.Name)
+	}
+
+	t.Log("the handshake fails for clients with an untrusted certificate")
+	untrusted := &TLSKeyPair{Certificate: fakeCA(fakeCATemplate()).Sign(fakeClientTemplate()).Certificate, CAs: ca.pool()}
+	_, err := (&http.Client{Transport: CreateDynamicTLSTransport(&fakeKeyPairLoader{keyPair: untrusted})}).Get(server.URL + "/healthz")
+	assert.Error(t, err)
+}
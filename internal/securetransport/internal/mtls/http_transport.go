@@ -10,6 +10,7 @@ var _ http.RoundTripper = (*dynamicTLSTransport)(nil)
 
 type dynamicTLSTransport struct {
 	loader interface{ KeyPair() *TLSKeyPair }
+	verify func(state tls.ConnectionState) error
 	inner  atomic.Pointer[transportWithKeyPair]
 }
 
@@ -18,11 +19,15 @@ type transportWithKeyPair struct {
 	KeyPair   *TLSKeyPair
 }
 
+// CreateDynamicTLSTransport returns a round tripper that presents the client certificate of
+// the loader, and checks the servers with the verifiers.
 func CreateDynamicTLSTransport(
 	loader interface{ KeyPair() *TLSKeyPair },
+	verifiers ...PeerVerifier,
 ) http.RoundTripper {
 	return &dynamicTLSTransport{
 		loader: loader,
+		verify: verifyConnection(verifiers),
 	}
 }
 
@@ -47,8 +52,9 @@ func (t *dynamicTLSTransport) innerTransport() *http.Transport {
 	// Create new transport.
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{
-			RootCAs:      nextKeyPair.CAs,
-			Certificates: []tls.Certificate{*nextKeyPair.Certificate},
+			RootCAs:          nextKeyPair.CAs,
+			Certificates:     []tls.Certificate{*nextKeyPair.Certificate},
+			VerifyConnection: t.verify,
 		},
 	}
 	t.inner.Store(&transportWithKeyPair{
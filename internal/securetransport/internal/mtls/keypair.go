@@ -7,6 +7,7 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"time"
 )
 
 var (
@@ -15,9 +16,10 @@ var (
 )
 
 type TLSKeyPair struct {
-	Certificate *tls.Certificate // Certificate is the certificate to use.
-	CAs         *x509.CertPool   // CAs is the CA pool to use.
-	Raw         *TLSKeyPairRaw   // Raw is the raw bytes of the key pair.
+	Certificate      *tls.Certificate  // Certificate is the certificate to use.
+	CAs              *x509.CertPool    // CAs is the CA pool to use.
+	Raw              *TLSKeyPairRaw    // Raw is the raw bytes of the key pair.
+	CABundleFindings []CABundleFinding // CABundleFindings are the issues found in the CA bundle.
 }
 
 func (k *TLSKeyPair) Equal(other *TLSKeyPair) bool {
@@ -53,18 +55,47 @@ func (s *TLSKeyPairRaw) Equal(other *TLSKeyPairRaw) bool {
 	return bytes.Equal(s.checkSum, other.checkSum)
 }
 
+// Parse parses the key pair, warning about the issues found in the CA bundle.
 func (s *TLSKeyPairRaw) Parse() (*TLSKeyPair, error) {
-	caPool := x509.NewCertPool()
-	if !caPool.AppendCertsFromPEM(s.caBytes) {
-		return nil, ErrAppendCACertsFromPEM
+	return s.ParseWithCABundlePolicy(CABundlePolicy{})
+}
+
+// ParseWithCABundlePolicy parses the key pair, applying the policy to the issues found in
+// the CA bundle. The findings are kept in TLSKeyPair.CABundleFindings.
+func (s *TLSKeyPairRaw) ParseWithCABundlePolicy(policy CABundlePolicy) (*TLSKeyPair, error) {
+	caPool, findings, err := parseCABundle(s.caBytes, policy, time.Now())
+	if err != nil {
+		return nil, err
 	}
 	cert, err := tls.X509KeyPair(s.certBytes, s.keyBytes)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrLoadCertificateAndKeyFromLocalFile, err)
 	}
+	if err := normalizeCertificate(&cert); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrLoadCertificateAndKeyFromLocalFile, err)
+	}
 	return &TLSKeyPair{
-		Certificate: &cert,
-		CAs:         caPool,
-		Raw:         s,
+		Certificate:      &cert,
+		CAs:              caPool,
+		Raw:              s,
+		CABundleFindings: findings,
 	}, nil
 }
+
+// normalizeCertificate reorders the chain presented after the leaf, see normalizeCertificateChain.
+func normalizeCertificate(cert *tls.Certificate) error {
+	certs := make([]*x509.Certificate, 0, len(cert.Certificate)-1)
+	for _, der := range cert.Certificate[1:] {
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			return fmt.Errorf("parse certificate chain: %w", err)
+		}
+		certs = append(certs, c)
+	}
+	chain := [][]byte{cert.Certificate[0]}
+	for _, c := range normalizeCertificateChain(cert.Leaf, certs) {
+		chain = append(chain, c.Raw)
+	}
+	cert.Certificate = chain
+	return nil
+}
@@ -0,0 +1,116 @@
+package mtls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// keyPairStore holds the key pair in use. Replacements are deduplicated by checksum,
+// validated before they are swapped in, and reported to the Watch subscribers.
+type keyPairStore struct {
+	validate     func(keyPair *TLSKeyPair) error
+	logger       *slog.Logger
+	bundlePolicy CABundlePolicy // applied when parsing raw key pairs
+
+	mu         sync.Mutex // serializes updates
+	keyPair    atomic.Pointer[TLSKeyPair]
+	generation atomic.Uint64
+	notifier   reloadNotifier[ReloadEvent]
+}
+
+func newKeyPairStore(validate func(keyPair *TLSKeyPair) error, logger *slog.Logger) *keyPairStore {
+	return &keyPairStore{validate: validate, logger: logger}
+}
+
+func (s *keyPairStore) KeyPair() *TLSKeyPair {
+	return s.keyPair.Load()
+}
+
+// Watch returns a channel of reload events, which is closed when the context is cancelled.
+func (s *keyPairStore) Watch(ctx context.Context) <-chan ReloadEvent {
+	return s.notifier.Watch(ctx)
+}
+
+// updateRaw parses, validates and swaps in the raw key pair, unless it's the one in use.
+func (s *keyPairStore) updateRaw(raw *TLSKeyPairRaw) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.keyPair.Load()
+	if previous != nil && previous.Raw.Equal(raw) {
+		return nil // No changes, skip loading.
+	}
+	keyPair, err := raw.ParseWithCABundlePolicy(s.bundlePolicy)
+	if err != nil {
+		return s.rejectLocked(nil, fmt.Errorf("parse key pair: %w", err))
+	}
+	if s.logger != nil {
+		logCABundleFindings(s.logger, keyPair.CABundleFindings)
+	}
+	return s.swapLocked(previous, keyPair)
+}
+
+// update validates and swaps in the key pair, unless it's the one in use.
+func (s *keyPairStore) update(keyPair *TLSKeyPair) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.keyPair.Load()
+	if keyPair == nil {
+		return s.rejectLocked(nil, fmt.Errorf("key pair is nil"))
+	}
+	if keyPair.Raw == nil {
+		return s.rejectLocked(nil, ErrKeyPairWithoutRaw)
+	}
+	if previous != nil && previous.Equal(keyPair) {
+		return nil
+	}
+	return s.swapLocked(previous, keyPair)
+}
+
+// reject reports a reload attempt that failed before a key pair could be parsed.
+func (s *keyPairStore) reject(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rejectLocked(nil, err)
+}
+
+func (s *keyPairStore) swapLocked(previous, keyPair *TLSKeyPair) error {
+	if err := s.validate(keyPair); err != nil {
+		var report *ValidationReport
+		if s.logger != nil && errors.As(err, &report) {
+			s.logger.Warn("Rejected key pair by validation", slog.Any("validation", report))
+		}
+		return s.rejectLocked(keyPair, fmt.Errorf("%w: %w", ErrValidateKeyPair, err))
+	}
+	s.keyPair.Store(keyPair)
+	if s.logger != nil {
+		s.logger.Info("Loaded key pair",
+			slog.String("subject", keyPair.Certificate.Leaf.Subject.String()),
+			slog.String("serial", formatSerial(keyPair.Certificate.Leaf.SerialNumber)),
+			slog.Time("not-after", keyPair.Certificate.Leaf.NotAfter),
+		)
+	}
+	s.notifier.notify(ReloadEvent{
+		Generation: s.generation.Add(1),
+		Previous:   previous,
+		Current:    keyPair,
+	})
+	return nil
+}
+
+func (s *keyPairStore) rejectLocked(candidate *TLSKeyPair, err error) error {
+	current := s.keyPair.Load()
+	s.notifier.notify(ReloadEvent{
+		Generation: s.generation.Load(),
+		Previous:   current,
+		Current:    current,
+		Rejected:   candidate,
+		Err:        err,
+	})
+	return err
+}
@@ -0,0 +1,52 @@
+package mtls
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyPairStore_updateRaw(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca            = fakeCA(fakeCATemplate())
+		keyPair       = ca.Sign(fakeServerTemplate())
+		secondKeyPair = ca.Sign(fakeServerTemplate())
+	)
+
+	store := newKeyPairStore(ValidateKeyPairForServerUsage, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := store.Watch(ctx)
+
+	t.Log("the first key pair is generation 1")
+	require.NoError(t, store.updateRaw(keyPair.Raw))
+	event := <-events
+	assert.Equal(t, uint64(1), event.Generation)
+	assert.Nil(t, event.Previous)
+
+	t.Log("the key pair in use is deduplicated by checksum")
+	current := store.KeyPair()
+	require.NoError(t, store.updateRaw(NewTLSKeyPairRaw(keyPair.Raw.caBytes, keyPair.Raw.certBytes, keyPair.Raw.keyBytes)))
+	assert.Same(t, current, store.KeyPair())
+	assert.Empty(t, events)
+
+	t.Log("a malformed key pair is rejected")
+	err := store.updateRaw(NewTLSKeyPairRaw(keyPair.Raw.caBytes, []byte("malformed"), keyPair.Raw.keyBytes))
+	require.Error(t, err)
+	event = <-events
+	assert.False(t, event.Succeeded())
+	assert.Nil(t, event.Rejected)
+	assert.Same(t, current, store.KeyPair())
+
+	t.Log("a new key pair is swapped in")
+	require.NoError(t, store.updateRaw(secondKeyPair.Raw))
+	event = <-events
+	assert.True(t, event.Succeeded())
+	assert.Equal(t, uint64(2), event.Generation)
+	assert.Same(t, current, event.Previous)
+	assert.True(t, store.KeyPair().Equal(secondKeyPair))
+}
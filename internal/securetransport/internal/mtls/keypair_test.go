@@ -1,8 +1,11 @@
 package mtls
 
 import (
+	"bytes"
+	"crypto/x509"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -63,3 +66,40 @@ func TestTLSKeyPair_Parse(t *testing.T) {
 		})
 	}
 }
+
+func TestTLSKeyPair_Parse_NormalizeChain(t *testing.T) {
+	t.Parallel()
+
+	var (
+		root          = fakeCA(fakeCATemplate())
+		intermediate1 = root.SignIntermediate(fakeCATemplate(func(template *x509.Certificate) {
+			template.Subject.CommonName = "test-intermediate-1"
+		}))
+		intermediate2 = intermediate1.SignIntermediate(fakeCATemplate(func(template *x509.Certificate) {
+			template.Subject.CommonName = "test-intermediate-2"
+		}))
+		unrelated = fakeCA(fakeCATemplate(func(template *x509.Certificate) {
+			template.Subject.CommonName = "test-unrelated"
+		}))
+		keyPair = intermediate2.Sign(fakeServerTemplate())
+	)
+
+	raw := NewTLSKeyPairRaw(
+		ToCertificatePEM(root.Certificate.Raw),
+		bytes.Join([][]byte{
+			ToCertificatePEM(keyPair.Certificate.Leaf.Raw),
+			ToCertificatePEM(root.Certificate.Raw),
+			ToCertificatePEM(unrelated.Certificate.Raw),
+			ToCertificatePEM(intermediate1.Certificate.Raw),
+			ToCertificatePEM(intermediate2.Certificate.Raw),
+		}, nil),
+		ToPrivateKeyPEM(keyPair.Certificate.PrivateKey),
+	)
+	parsed, err := raw.Parse()
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{
+		keyPair.Certificate.Leaf.Raw,
+		intermediate2.Certificate.Raw,
+		intermediate1.Certificate.Raw,
+	}, parsed.Certificate.Certificate, "the chain should be ordered from the leaf up, without the root and unrelated certificates")
+}
@@ -0,0 +1,383 @@
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	kubernetesServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	kubernetesSecretTypeTLS     = "kubernetes.io/tls"
+)
+
+var (
+	DefaultKubernetesCABundleKey    = "ca-bundle.pem"
+	DefaultKubernetesRequestTimeout = 10 * time.Second
+)
+
+var ErrKubernetesAPI = errors.New("kubernetes API request")
+
+type KubernetesSecretSourceOptions struct {
+	Namespace         string             // Namespace of the Secret and ConfigMap; defaults to the namespace of the pod
+	Secret            string             // Name of the kubernetes.io/tls Secret holding tls.crt, tls.key and optionally ca.crt
+	CABundleConfigMap string             // Name of a ConfigMap holding the CA bundle, e.g. synced by trust-manager; replaces ca.crt
+	CABundleKey       string             // Key of the CA bundle in the ConfigMap; defaults to ca-bundle.pem
+	KeyPassphrase     PassphraseProvider // Passphrase of an encrypted private key, called on every reload
+
+	APIServer  string        // URL of the API server; defaults to the in-cluster service
+	TokenFile  string        // Path to the bearer token, read on every request; defaults to the service account token in-cluster
+	CAFile     string        // Path to the CA bundle of the API server; defaults to the service account CA in-cluster
+	HTTPClient *http.Client  // Client for the API server; replaces CAFile
+	WatchRetry time.Duration // Delay before re-establishing a broken watch, doubled on every failure
+	Logger     *slog.Logger  // Logger for watch failures; defaults to slog.Default()
+}
+
+func (opts *KubernetesSecretSourceOptions) defaults() error {
+	if opts.Secret == "" {
+		return fmt.Errorf("secret name is empty")
+	}
+	if opts.APIServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return fmt.Errorf("API server is empty and not running in a Kubernetes cluster")
+		}
+		opts.APIServer = "https://" + net.JoinHostPort(host, port)
+		if opts.TokenFile == "" {
+			opts.TokenFile = path.Join(kubernetesServiceAccountDir, "token")
+		}
+		if opts.CAFile == "" {
+			opts.CAFile = path.Join(kubernetesServiceAccountDir, "ca.crt")
+		}
+	}
+	if opts.Namespace == "" {
+		namespace, err := os.ReadFile(path.Join(kubernetesServiceAccountDir, "namespace"))
+		if err != nil {
+			return fmt.Errorf("namespace is empty and can't be read from the service account: %w", err)
+		}
+		opts.Namespace = strings.TrimSpace(string(namespace))
+	}
+	if opts.CABundleKey == "" {
+		opts.CABundleKey = DefaultKubernetesCABundleKey
+	}
+	if opts.HTTPClient == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if opts.CAFile != "" {
+			caPEM, err := os.ReadFile(opts.CAFile)
+			if err != nil {
+				return fmt.Errorf("read API server CA file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(caPEM) {
+				return fmt.Errorf("API server CA file: %w", ErrAppendCACertsFromPEM)
+			}
+			transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+		}
+		opts.HTTPClient = &http.Client{Transport: transport}
+	}
+	if opts.WatchRetry == 0 {
+		opts.WatchRetry = DefaultRetryBackoff
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return nil
+}
+
+var (
+	_ WatchableKeyPairSource = (*KubernetesSecretSource)(nil)
+	_ PollableKeyPairSource  = (*KubernetesSecretSource)(nil)
+)
+
+// KubernetesSecretSource reads the key pair from a kubernetes.io/tls Secret through the
+// API server, and watches it so that rotations are picked up without the kubelet sync
+// delay of mounted volumes. The CA bundle is taken from the ca.crt key of the Secret,
+// or from a ConfigMap such as the one trust-manager maintains.
+//
+// The service account needs the get and watch verbs on the Secret and the ConfigMap;
+// both can be restricted with resourceNames.
+type KubernetesSecretSource struct {
+	options KubernetesSecretSourceOptions
+
+	mu      sync.Mutex
+	read    map[string]string // resource -> resourceVersion of the object as of the last read
+	watched map[string]string // resource -> resourceVersion of the object as last seen by its watch; only while the watch is up
+}
+
+func NewKubernetesSecretSource(options KubernetesSecretSourceOptions) (*KubernetesSecretSource, error) {
+	if err := options.defaults(); err != nil {
+		return nil, err
+	}
+	return &KubernetesSecretSource{
+		options: options,
+		read:    make(map[string]string),
+		watched: make(map[string]string),
+	}, nil
+}
+
+// resources returns the names of the watched objects by their resource.
+func (s *KubernetesSecretSource) resources() map[string]string {
+	resources := map[string]string{"secrets": s.options.Secret}
+	if s.options.CABundleConfigMap != "" {
+		resources["configmaps"] = s.options.CABundleConfigMap
+	}
+	return resources
+}
+
+type kubernetesObjectMeta struct {
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+type kubernetesSecret struct {
+	Metadata kubernetesObjectMeta `json:"metadata"`
+	Type     string               `json:"type"`
+	Data     map[string][]byte    `json:"data"`
+}
+
+type kubernetesConfigMap struct {
+	Metadata kubernetesObjectMeta `json:"metadata"`
+	Data     map[string]string    `json:"data"`
+}
+
+type kubernetesStatus struct {
+	Message string `json:"message"`
+	Reason  string `json:"reason"`
+	Code    int    `json:"code"`
+}
+
+type kubernetesWatchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+func (s *KubernetesSecretSource) ReadKeyPair(ctx context.Context) (*TLSKeyPairRaw, error) {
+	var secret kubernetesSecret
+	if err := s.get(ctx, "secrets", s.options.Secret, &secret); err != nil {
+		return nil, err
+	}
+	if secret.Type != kubernetesSecretTypeTLS {
+		return nil, fmt.Errorf("secret %s/%s has type %q, expected %q",
+			s.options.Namespace, s.options.Secret, secret.Type, kubernetesSecretTypeTLS)
+	}
+	certPEM, keyPEM, bundlePEM := secret.Data["tls.crt"], secret.Data["tls.key"], secret.Data["ca.crt"]
+	if len(certPEM) == 0 || len(keyPEM) == 0 {
+		return nil, fmt.Errorf("secret %s/%s has no tls.crt or tls.key", s.options.Namespace, s.options.Secret)
+	}
+
+	versions := map[string]string{"secrets": secret.Metadata.ResourceVersion}
+	if s.options.CABundleConfigMap != "" {
+		var configMap kubernetesConfigMap
+		if err := s.get(ctx, "configmaps", s.options.CABundleConfigMap, &configMap); err != nil {
+			return nil, err
+		}
+		bundle, ok := configMap.Data[s.options.CABundleKey]
+		if !ok {
+			return nil, fmt.Errorf("configmap %s/%s has no %s",
+				s.options.Namespace, s.options.CABundleConfigMap, s.options.CABundleKey)
+		}
+		bundlePEM = []byte(bundle)
+		versions["configmaps"] = configMap.Metadata.ResourceVersion
+	}
+
+	keyPEM, err := decryptPrivateKeyPEM(keyPEM, s.options.KeyPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.read = versions
+	s.mu.Unlock()
+	return NewTLSKeyPairRaw(bundlePEM, certPEM, keyPEM), nil
+}
+
+// KeyPairChanged reports whether the objects may differ from the ones read last. While the
+// watches are up, changes are notified by them, so polls only read the objects again when a
+// watch is down or has seen a resourceVersion that was not read yet.
+func (s *KubernetesSecretSource) KeyPairChanged() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for resource := range s.resources() {
+		version, ok := s.watched[resource]
+		if !ok || (version != "" && version != s.read[resource]) {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchKeyPair watches the Secret and the ConfigMap until the context is cancelled.
+// Broken watches are re-established with exponential backoff; the loader only polls the
+// API server while a watch is down.
+func (s *KubernetesSecretSource) WatchKeyPair(ctx context.Context, notify func()) {
+	resources := s.resources()
+	done := make(chan struct{}, len(resources))
+	for resource, name := range resources {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			s.watchObject(ctx, resource, name, notify)
+		}()
+	}
+	for range resources {
+		<-done
+	}
+}
+
+func (s *KubernetesSecretSource) watchObject(ctx context.Context, resource, name string, notify func()) {
+	var (
+		resourceVersion string
+		failures        int
+	)
+	for {
+		started := time.Now()
+		err := s.watch(ctx, resource, name, &resourceVersion, notify)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil && time.Since(started) >= s.options.WatchRetry {
+			failures = 0
+			continue // The API server closed a healthy watch; resume right away.
+		}
+		if err == nil {
+			err = errors.New("watch closed early")
+		}
+		failures++
+		delay := retryBackoff(failures, s.options.WatchRetry, DefaultMaxRetryBackoff)
+		s.options.Logger.Warn("Failed to watch Kubernetes object, falling back to polling",
+			slog.String("resource", resource),
+			slog.String("name", name),
+			slog.String("error", err.Error()),
+			slog.Duration("retry-in", delay),
+		)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// watch streams the events of a single object and calls notify for every change. It
+// resumes from resourceVersion, and keeps it up to date for the next watch. The object is
+// marked as watched for KeyPairChanged until the stream ends.
+func (s *KubernetesSecretSource) watch(
+	ctx context.Context,
+	resource, name string,
+	resourceVersion *string,
+	notify func(),
+) error {
+	query := url.Values{
+		"watch":               {"true"},
+		"allowWatchBookmarks": {"true"},
+		"fieldSelector":       {"metadata.name=" + name},
+	}
+	if *resourceVersion != "" {
+		query.Set("resourceVersion", *resourceVersion)
+	}
+	resp, err := s.do(ctx, s.collectionPath(resource)+"?"+query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	s.mu.Lock()
+	s.watched[resource] = ""
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.watched, resource)
+		s.mu.Unlock()
+	}()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event kubernetesWatchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("decode watch event: %w", err)
+		}
+		if event.Type == "ERROR" {
+			var status kubernetesStatus
+			_ = json.Unmarshal(event.Object, &status)
+			if status.Code == http.StatusGone {
+				*resourceVersion = "" // Too old to resume from; start over with the current state.
+			}
+			return fmt.Errorf("%w: watch %s/%s: %s", ErrKubernetesAPI, resource, name, status.Message)
+		}
+		var object struct {
+			Metadata kubernetesObjectMeta `json:"metadata"`
+		}
+		if err := json.Unmarshal(event.Object, &object); err != nil {
+			return fmt.Errorf("decode watch event object: %w", err)
+		}
+		*resourceVersion = object.Metadata.ResourceVersion
+		if event.Type != "BOOKMARK" {
+			// Bookmarks carry the resourceVersion of the collection, not of the object.
+			s.mu.Lock()
+			s.watched[resource] = object.Metadata.ResourceVersion
+			s.mu.Unlock()
+			notify()
+		}
+	}
+}
+
+func (s *KubernetesSecretSource) get(ctx context.Context, resource, name string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultKubernetesRequestTimeout)
+	defer cancel()
+	resp, err := s.do(ctx, s.collectionPath(resource)+"/"+url.PathEscape(name))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: decode %s/%s: %w", ErrKubernetesAPI, resource, name, err)
+	}
+	return nil
+}
+
+func (s *KubernetesSecretSource) collectionPath(resource string) string {
+	return "/api/v1/namespaces/" + url.PathEscape(s.options.Namespace) + "/" + resource
+}
+
+// do sends a GET request to the API server, and turns non-200 responses into errors.
+func (s *KubernetesSecretSource) do(ctx context.Context, pathAndQuery string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(s.options.APIServer, "/")+pathAndQuery, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrKubernetesAPI, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if s.options.TokenFile != "" {
+		// The token is read on every request, since projected service account tokens rotate.
+		token, err := os.ReadFile(s.options.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: read token file: %w", ErrKubernetesAPI, err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := s.options.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrKubernetesAPI, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var status kubernetesStatus
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&status)
+		return nil, fmt.Errorf("%w: GET %s: %s: %s", ErrKubernetesAPI, req.URL.Path, resp.Status, status.Message)
+	}
+	return resp, nil
+}
@@ -0,0 +1,352 @@
+package mtls
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeKubernetesToken = "fake-token"
+
+// fakeKubernetesAPIServer serves Secrets and ConfigMaps of a single namespace,
+// including watches filtered by name.
+type fakeKubernetesAPIServer struct {
+	*httptest.Server
+
+	mu              sync.Mutex
+	objects         map[string]map[string]any // resource -> name -> object
+	resourceVersion int
+	watchers        map[string][]chan kubernetesWatchEvent // resource/name -> watchers
+	gets            int
+}
+
+func newFakeKubernetesAPIServer(t *testing.T) *fakeKubernetesAPIServer {
+	s := &fakeKubernetesAPIServer{
+		objects:  map[string]map[string]any{"secrets": {}, "configmaps": {}},
+		watchers: map[string][]chan kubernetesWatchEvent{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeKubernetesAPIServer) SetSecret(name, secretType string, data map[string][]byte) {
+	s.set("secrets", name, func(meta kubernetesObjectMeta) any {
+		return kubernetesSecret{Metadata: meta, Type: secretType, Data: data}
+	})
+}
+
+func (s *fakeKubernetesAPIServer) SetConfigMap(name string, data map[string]string) {
+	s.set("configmaps", name, func(meta kubernetesObjectMeta) any {
+		return kubernetesConfigMap{Metadata: meta, Data: data}
+	})
+}
+
+func (s *fakeKubernetesAPIServer) set(resource, name string, object func(meta kubernetesObjectMeta) any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resourceVersion++
+	obj := object(kubernetesObjectMeta{Name: name, ResourceVersion: strconv.Itoa(s.resourceVersion)})
+	s.objects[resource][name] = obj
+	raw, err := json.Marshal(obj)
+	PanicIfErr(err)
+	for _, w := range s.watchers[resource+"/"+name] {
+		w <- kubernetesWatchEvent{Type: "MODIFIED", Object: raw}
+	}
+}
+
+func (s *fakeKubernetesAPIServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+fakeKubernetesToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(kubernetesStatus{Message: "Unauthorized", Code: http.StatusUnauthorized})
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/namespaces/default/"), "/")
+	resource := parts[0]
+
+	if r.URL.Query().Get("watch") == "true" {
+		name := strings.TrimPrefix(r.URL.Query().Get("fieldSelector"), "metadata.name=")
+		s.serveWatch(w, r, resource, name)
+		return
+	}
+
+	s.mu.Lock()
+	s.gets++
+	obj, ok := s.objects[resource][parts[len(parts)-1]]
+	s.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(kubernetesStatus{Message: "not found", Code: http.StatusNotFound})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(obj)
+}
+
+func (s *fakeKubernetesAPIServer) serveWatch(w http.ResponseWriter, r *http.Request, resource, name string) {
+	events := make(chan kubernetesWatchEvent, 16)
+	key := resource + "/" + name
+	s.mu.Lock()
+	s.watchers[key] = append(s.watchers[key], events)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, ch := range s.watchers[key] {
+			if ch == events {
+				s.watchers[key] = append(s.watchers[key][:i], s.watchers[key][i+1:]...)
+				break
+			}
+		}
+	}()
+
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			_ = encoder.Encode(event)
+			w.(http.Flusher).Flush()
+		}
+	}
+}
+
+func (s *fakeKubernetesAPIServer) Watchers(resource, name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.watchers[resource+"/"+name])
+}
+
+// Gets returns the number of objects read, not counting watches.
+func (s *fakeKubernetesAPIServer) Gets() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gets
+}
+
+func fakeKubernetesSourceOptions(t *testing.T, server *fakeKubernetesAPIServer) KubernetesSecretSourceOptions {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte(fakeKubernetesToken+"\n"), 0o600))
+	return KubernetesSecretSourceOptions{
+		Namespace:  "default",
+		Secret:     "server-tls",
+		APIServer:  server.URL,
+		TokenFile:  tokenFile,
+		HTTPClient: server.Client(),
+		WatchRetry: 10 * time.Millisecond,
+	}
+}
+
+func tlsSecretData(keyPair *TLSKeyPair) map[string][]byte {
+	return map[string][]byte{
+		"tls.crt": keyPair.Raw.certBytes,
+		"tls.key": keyPair.Raw.keyBytes,
+		"ca.crt":  keyPair.Raw.caBytes,
+	}
+}
+
+func TestKubernetesSecretSource_ReadKeyPair(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca      = fakeCA(fakeCATemplate())
+		newCA   = fakeCA(fakeCATemplate())
+		keyPair = ca.Sign(fakeServerTemplate())
+	)
+
+	t.Run("it should read the key pair and CA from the secret", func(t *testing.T) {
+		t.Parallel()
+		server := newFakeKubernetesAPIServer(t)
+		server.SetSecret("server-tls", "kubernetes.io/tls", tlsSecretData(keyPair))
+
+		source, err := NewKubernetesSecretSource(fakeKubernetesSourceOptions(t, server))
+		require.NoError(t, err)
+		raw, err := source.ReadKeyPair(context.Background())
+		require.NoError(t, err)
+		assert.True(t, raw.Equal(keyPair.Raw))
+	})
+
+	t.Run("it should read the CA bundle from the configmap", func(t *testing.T) {
+		t.Parallel()
+		server := newFakeKubernetesAPIServer(t)
+		server.SetSecret("server-tls", "kubernetes.io/tls", tlsSecretData(keyPair))
+		bundle := string(ToCertificatePEM(ca.Certificate.Raw)) + string(ToCertificatePEM(newCA.Certificate.Raw))
+		server.SetConfigMap("ca-bundle", map[string]string{"ca-bundle.pem": bundle})
+
+		options := fakeKubernetesSourceOptions(t, server)
+		options.CABundleConfigMap = "ca-bundle"
+		source, err := NewKubernetesSecretSource(options)
+		require.NoError(t, err)
+		raw, err := source.ReadKeyPair(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []byte(bundle), raw.caBytes)
+		assert.Equal(t, keyPair.Raw.certBytes, raw.certBytes)
+	})
+
+	t.Run("it should fail if the configmap lacks the CA bundle key", func(t *testing.T) {
+		t.Parallel()
+		server := newFakeKubernetesAPIServer(t)
+		server.SetSecret("server-tls", "kubernetes.io/tls", tlsSecretData(keyPair))
+		server.SetConfigMap("ca-bundle", map[string]string{"other.pem": "x"})
+
+		options := fakeKubernetesSourceOptions(t, server)
+		options.CABundleConfigMap = "ca-bundle"
+		source, err := NewKubernetesSecretSource(options)
+		require.NoError(t, err)
+		_, err = source.ReadKeyPair(context.Background())
+		require.ErrorContains(t, err, "has no ca-bundle.pem")
+	})
+
+	t.Run("it should fail if the secret is not a TLS secret", func(t *testing.T) {
+		t.Parallel()
+		server := newFakeKubernetesAPIServer(t)
+		server.SetSecret("server-tls", "Opaque", tlsSecretData(keyPair))
+
+		source, err := NewKubernetesSecretSource(fakeKubernetesSourceOptions(t, server))
+		require.NoError(t, err)
+		_, err = source.ReadKeyPair(context.Background())
+		require.ErrorContains(t, err, `has type "Opaque"`)
+	})
+
+	t.Run("it should fail if the secret does not exist", func(t *testing.T) {
+		t.Parallel()
+		server := newFakeKubernetesAPIServer(t)
+
+		source, err := NewKubernetesSecretSource(fakeKubernetesSourceOptions(t, server))
+		require.NoError(t, err)
+		_, err = source.ReadKeyPair(context.Background())
+		require.ErrorIs(t, err, ErrKubernetesAPI)
+		require.ErrorContains(t, err, "404 Not Found")
+	})
+
+	t.Run("it should fail without a valid token", func(t *testing.T) {
+		t.Parallel()
+		server := newFakeKubernetesAPIServer(t)
+		server.SetSecret("server-tls", "kubernetes.io/tls", tlsSecretData(keyPair))
+
+		options := fakeKubernetesSourceOptions(t, server)
+		options.TokenFile = ""
+		source, err := NewKubernetesSecretSource(options)
+		require.NoError(t, err)
+		_, err = source.ReadKeyPair(context.Background())
+		require.ErrorIs(t, err, ErrKubernetesAPI)
+		require.ErrorContains(t, err, "401 Unauthorized")
+	})
+}
+
+func TestKubernetesSecretSource_WatchKeyPair(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca            = fakeCA(fakeCATemplate())
+		newCA         = fakeCA(fakeCATemplate())
+		keyPair       = ca.Sign(fakeServerTemplate())
+		secondKeyPair = ca.Sign(fakeServerTemplate())
+	)
+
+	server := newFakeKubernetesAPIServer(t)
+	server.SetSecret("server-tls", "kubernetes.io/tls", tlsSecretData(keyPair))
+	server.SetConfigMap("ca-bundle", map[string]string{"ca-bundle.pem": string(ToCertificatePEM(ca.Certificate.Raw))})
+
+	options := fakeKubernetesSourceOptions(t, server)
+	options.CABundleConfigMap = "ca-bundle"
+	source, err := NewKubernetesSecretSource(options)
+	require.NoError(t, err)
+
+	loader, err := NewSourceServerTLSConfigLoader(source, SourceTLSConfigLoaderOptions{ReloadInterval: time.Hour})
+	require.NoError(t, err)
+	assert.True(t, loader.loader.KeyPair().Equal(keyPair))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- loader.StartLoop(ctx) }()
+	defer func() {
+		cancel()
+		require.NoError(t, <-done)
+	}()
+	require.Eventually(t, func() bool {
+		return server.Watchers("secrets", "server-tls") == 1 && server.Watchers("configmaps", "ca-bundle") == 1
+	}, time.Second, 5*time.Millisecond)
+
+	t.Log("a rotated secret is picked up from the watch")
+	server.SetSecret("server-tls", "kubernetes.io/tls", tlsSecretData(secondKeyPair))
+	assert.Eventually(t, func() bool {
+		return loader.loader.KeyPair().Equal(secondKeyPair)
+	}, time.Second, 5*time.Millisecond)
+
+	t.Log("a rotated CA bundle is picked up from the watch")
+	bundle := string(ToCertificatePEM(ca.Certificate.Raw)) + string(ToCertificatePEM(newCA.Certificate.Raw))
+	server.SetConfigMap("ca-bundle", map[string]string{"ca-bundle.pem": bundle})
+	assert.Eventually(t, func() bool {
+		return string(loader.loader.KeyPair().Raw.caBytes) == bundle
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestKubernetesSecretSource_KeyPairChanged(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca            = fakeCA(fakeCATemplate())
+		keyPair       = ca.Sign(fakeServerTemplate())
+		secondKeyPair = ca.Sign(fakeServerTemplate())
+	)
+
+	server := newFakeKubernetesAPIServer(t)
+	server.SetSecret("server-tls", "kubernetes.io/tls", tlsSecretData(keyPair))
+	server.SetConfigMap("ca-bundle", map[string]string{"ca-bundle.pem": string(ToCertificatePEM(ca.Certificate.Raw))})
+
+	options := fakeKubernetesSourceOptions(t, server)
+	options.CABundleConfigMap = "ca-bundle"
+	source, err := NewKubernetesSecretSource(options)
+	require.NoError(t, err)
+
+	loader, err := NewSourceServerTLSConfigLoader(source, SourceTLSConfigLoaderOptions{ReloadInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	assert.True(t, source.KeyPairChanged(), "the objects are polled until they are watched")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- loader.StartLoop(ctx) }()
+	stop := sync.OnceFunc(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+	defer stop()
+	require.Eventually(t, func() bool {
+		return !source.KeyPairChanged()
+	}, time.Second, 5*time.Millisecond)
+
+	t.Log("the objects are not read on polls while the watches are up")
+	gets := server.Gets()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, gets, server.Gets())
+
+	t.Log("a rotated secret is read from the watch, then the polls stop reading again")
+	server.SetSecret("server-tls", "kubernetes.io/tls", tlsSecretData(secondKeyPair))
+	assert.Eventually(t, func() bool {
+		return loader.loader.KeyPair().Equal(secondKeyPair)
+	}, time.Second, 5*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return !source.KeyPairChanged()
+	}, time.Second, 5*time.Millisecond)
+	gets = server.Gets()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, gets, server.Gets())
+
+	t.Log("the objects are polled again once the watches are down")
+	stop()
+	assert.True(t, source.KeyPairChanged())
+}
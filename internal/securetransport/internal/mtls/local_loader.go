@@ -3,106 +3,269 @@ package mtls
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
-	"sync/atomic"
+	"sync"
 	"time"
 )
 
-var DefaultReloadInterval = 10 * time.Second
-
 type LocalFileTLSConfigLoaderOptions struct {
 	CABundle       string                          // Path to the CA bundle PEM file
 	Certificate    string                          // Path to the certificate PEM file
 	Key            string                          // Path to the key PEM file
-	ReloadInterval time.Duration                   // Interval to reload the TLS config
+	CombinedPEM    string                          // Path to a PEM file with the key, certificate and chain; replaces Certificate and Key
+	CAInCombined   bool                            // Take the CA bundle from the self-signed CAs in CombinedPEM; replaces CABundle
+	PKCS12         string                          // Path to a PKCS#12 keystore; replaces Certificate and Key, and CABundle if empty
+	KeyPassphrase  PassphraseProvider              // Passphrase of an encrypted private key or of the PKCS#12 keystore, asked on every reload
+	ReloadInterval time.Duration                   // Interval to poll the files when they can't be watched
+	WatchDebounce  time.Duration                   // Quiet period after a file event before reloading
 	Validate       func(keyPair *TLSKeyPair) error // Validate the key pair after loading
+	PeerVerifiers  []PeerVerifier                  // Verify the peers of connections, e.g. with VerifyPeerSPIFFEID
+	OCSPStapling   *OCSPStaplingOptions            // Staple OCSP responses for the leaf; server loaders only, rejected by client loaders
+	ClientAuth     ClientAuthMode                  // Whether clients must present a certificate; server loaders only, rejected by client loaders; requires one by default
+	CryptoPolicy   *CryptoPolicy                   // Reject key pairs violating the policy; a check of the default Validate, or chained after a custom one
+	CABundlePolicy CABundlePolicy                  // Warn about, drop or reject problematic CA certificates; warns by default
+
+	MinRemainingValidity         time.Duration // Reject leaves expiring sooner in the default Validate of client and server loaders; defaults to DefaultMinRemainingValidityFraction of the lifetime, up to MinimumCertificateValidityDuration
+	MinRemainingValidityFraction float64       // Minimum remaining validity as a fraction of the leaf lifetime, e.g. 0.2; replaces MinRemainingValidity
+
+	Logger                 *slog.Logger  // Logger for reload outcomes; defaults to slog.Default()
+	OnError                func(error)   // Called with the error of every failed reload attempt
+	MaxConsecutiveFailures int           // StartLoop returns an error after this many failures in a row; 0 retries forever
+	RetryBackoff           time.Duration // Delay before retrying a failed reload, doubled on every failure
+	MaxRetryBackoff        time.Duration // Upper bound of the retry delay
 }
 
 func (opts *LocalFileTLSConfigLoaderOptions) defaults() error {
-	if file, err := os.Stat(opts.CABundle); err != nil || file.IsDir() {
-		return fmt.Errorf("check CA bundle file: %w", err)
-	}
-	if file, err := os.Stat(opts.Certificate); err != nil || file.IsDir() {
-		return fmt.Errorf("check certificate file: %w", err)
+	switch {
+	case opts.CAInCombined:
+		if opts.CombinedPEM == "" {
+			return fmt.Errorf("CA in combined PEM requires a combined PEM file")
+		}
+		if opts.CABundle != "" {
+			return fmt.Errorf("CA bundle must not be set together with CA in combined PEM")
+		}
+	case opts.PKCS12 != "" && opts.CABundle == "":
+		// Trust anchors come from the keystore.
+	default:
+		if err := checkFile(opts.CABundle); err != nil {
+			return fmt.Errorf("check CA bundle file: %w", err)
+		}
 	}
-	if file, err := os.Stat(opts.Key); err != nil || file.IsDir() {
-		return fmt.Errorf("check key file: %w", err)
+	switch {
+	case opts.PKCS12 != "":
+		if opts.Certificate != "" || opts.Key != "" || opts.CombinedPEM != "" {
+			return fmt.Errorf("certificate, key and combined PEM must not be set together with PKCS#12")
+		}
+		if err := checkFile(opts.PKCS12); err != nil {
+			return fmt.Errorf("check PKCS#12 file: %w", err)
+		}
+	case opts.CombinedPEM != "":
+		if opts.Certificate != "" || opts.Key != "" {
+			return fmt.Errorf("certificate and key must not be set together with combined PEM")
+		}
+		if err := checkFile(opts.CombinedPEM); err != nil {
+			return fmt.Errorf("check combined PEM file: %w", err)
+		}
+	default:
+		if err := checkFile(opts.Certificate); err != nil {
+			return fmt.Errorf("check certificate file: %w", err)
+		}
+		if err := checkFile(opts.Key); err != nil {
+			return fmt.Errorf("check key file: %w", err)
+		}
 	}
+	// The file watcher also retries at the reload interval.
 	if opts.ReloadInterval == 0 {
 		opts.ReloadInterval = DefaultReloadInterval
 	}
-	if opts.Validate == nil {
-		return fmt.Errorf("validate function is nil")
+	if opts.WatchDebounce == 0 {
+		opts.WatchDebounce = DefaultWatchDebounce
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return nil
+}
+
+func (opts *LocalFileTLSConfigLoaderOptions) sourceOptions() SourceTLSConfigLoaderOptions {
+	return SourceTLSConfigLoaderOptions{
+		ReloadInterval:               opts.ReloadInterval,
+		Validate:                     opts.Validate,
+		PeerVerifiers:                opts.PeerVerifiers,
+		OCSPStapling:                 opts.OCSPStapling,
+		ClientAuth:                   opts.ClientAuth,
+		CryptoPolicy:                 opts.CryptoPolicy,
+		CABundlePolicy:               opts.CABundlePolicy,
+		MinRemainingValidity:         opts.MinRemainingValidity,
+		MinRemainingValidityFraction: opts.MinRemainingValidityFraction,
+		Logger:                       opts.Logger,
+		OnError:                      opts.OnError,
+		MaxConsecutiveFailures:       opts.MaxConsecutiveFailures,
+		RetryBackoff:                 opts.RetryBackoff,
+		MaxRetryBackoff:              opts.MaxRetryBackoff,
+	}
+}
+
+// filePaths returns the files backing the key pair, including the passphrase file of a
+// PassphraseFromFile provider.
+func (opts *LocalFileTLSConfigLoaderOptions) filePaths() []string {
+	var rv []string
+	if opts.CABundle != "" {
+		rv = append(rv, opts.CABundle)
+	}
+	switch {
+	case opts.PKCS12 != "":
+		rv = append(rv, opts.PKCS12)
+	case opts.CombinedPEM != "":
+		rv = append(rv, opts.CombinedPEM)
+	default:
+		rv = append(rv, opts.Certificate, opts.Key)
+	}
+	if path := passphrasePath(opts.KeyPassphrase); path != "" {
+		rv = append(rv, path)
+	}
+	return rv
+}
+
+func checkFile(path string) error {
+	file, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if file.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
 	}
 	return nil
 }
 
 type LocalFileTLSConfigLoader struct {
-	options LocalFileTLSConfigLoaderOptions
-	keyPair atomic.Pointer[TLSKeyPair]
+	source *localFileSource
+	loader *SourceTLSConfigLoader
 }
 
 func NewLocalFileTLSConfigLoader(options LocalFileTLSConfigLoaderOptions) (*LocalFileTLSConfigLoader, error) {
-	if err := options.defaults(); err != nil {
+	source, err := newLocalFileSource(options)
+	if err != nil {
 		return nil, err
 	}
-	loader := &LocalFileTLSConfigLoader{options: options}
-	if err := loader.loadKeyPair(); err != nil {
+	loader, err := NewSourceTLSConfigLoader(source, source.options.sourceOptions())
+	if err != nil {
 		return nil, err
 	}
-	return loader, nil
+	return &LocalFileTLSConfigLoader{source: source, loader: loader}, nil
 }
 
+// StartLoop reloads the key pair whenever the files change until the context is cancelled.
 func (l *LocalFileTLSConfigLoader) StartLoop(ctx context.Context) error {
-	ticker := time.NewTicker(l.options.ReloadInterval)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case <-ticker.C:
-			if err := l.loadKeyPair(); err != nil {
-				// TODO: log error but continue to watch for changes
-			}
-		}
-	}
+	return l.loader.StartLoop(ctx)
 }
 
 func (l *LocalFileTLSConfigLoader) KeyPair() *TLSKeyPair {
-	return l.keyPair.Load()
+	return l.loader.KeyPair()
 }
 
-func (l *LocalFileTLSConfigLoader) loadKeyPair() error {
-	nextRaw, err := l.readKeyPairRaw()
-	if err != nil {
-		return err
-	}
-	if l.keyPair.Load() != nil && l.keyPair.Load().Raw.Equal(nextRaw) {
-		return nil // No changes, skip loading.
+// Watch returns a channel of reload events, which is closed when the context is cancelled.
+func (l *LocalFileTLSConfigLoader) Watch(ctx context.Context) <-chan ReloadEvent {
+	return l.loader.Watch(ctx)
+}
+
+var (
+	_ WatchableKeyPairSource = (*localFileSource)(nil)
+	_ PollableKeyPairSource  = (*localFileSource)(nil)
+)
+
+// localFileSource reads the key pair from local files.
+type localFileSource struct {
+	options LocalFileTLSConfigLoaderOptions
+
+	mu     sync.Mutex
+	stamps []fileStamp // stamps of the files as of the last read
+}
+
+func newLocalFileSource(options LocalFileTLSConfigLoaderOptions) (*localFileSource, error) {
+	if err := options.defaults(); err != nil {
+		return nil, err
 	}
-	keyPair, err := nextRaw.Parse()
+	return &localFileSource{options: options}, nil
+}
+
+func (s *localFileSource) ReadKeyPair(ctx context.Context) (*TLSKeyPairRaw, error) {
+	// Stat before reading, so that a write racing with the read is caught by the next poll.
+	stamps, err := statFiles(s.options.filePaths())
 	if err != nil {
-		return fmt.Errorf("parse key pair: %w", err)
+		return nil, fmt.Errorf("stat key pair files: %w", err)
 	}
-	if err := l.options.Validate(keyPair); err != nil {
-		return fmt.Errorf("validate key pair: %w", err)
+	raw, err := s.readKeyPairRaw()
+	if err != nil {
+		return nil, err
 	}
-	l.keyPair.Store(keyPair)
-	return nil
+	s.mu.Lock()
+	s.stamps = stamps
+	s.mu.Unlock()
+	return raw, nil
 }
 
-func (l *LocalFileTLSConfigLoader) readKeyPairRaw() (*TLSKeyPairRaw, error) {
-	bundlePEM, err := os.ReadFile(l.options.CABundle)
+// KeyPairChanged reports whether the files may differ from the ones read last.
+func (s *localFileSource) KeyPairChanged() bool {
+	stamps, err := statFiles(s.options.filePaths())
 	if err != nil {
-		return nil, fmt.Errorf("read CA bundle from local file: %w", err)
+		return true // Let ReadKeyPair surface the error.
 	}
-	certPEM, err := os.ReadFile(l.options.Certificate)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !fileStampsEqual(s.stamps, stamps)
+}
+
+// WatchKeyPair watches the parent directories of the files until the context is cancelled.
+func (s *localFileSource) WatchKeyPair(ctx context.Context, notify func()) {
+	watchFiles(s.options.filePaths(), s.options.WatchDebounce, s.options.ReloadInterval, s.options.Logger)(ctx, notify)
+}
+
+func (s *localFileSource) readKeyPairRaw() (*TLSKeyPairRaw, error) {
+	paths := s.options.filePaths()
+	contents, err := readFilesSnapshot(paths)
 	if err != nil {
-		return nil, fmt.Errorf("read certificate from local file: %w", err)
+		return nil, fmt.Errorf("read key pair from local files: %w", err)
+	}
+	files := make(map[string][]byte, len(paths))
+	for i, path := range paths {
+		files[path] = contents[i]
+	}
+
+	passphrase := s.options.KeyPassphrase
+	if path := passphrasePath(passphrase); path != "" {
+		// Take the passphrase from the snapshot rather than reading the file again.
+		passphrase = PassphraseFunc(func() ([]byte, error) { return trimPassphrase(files[path]), nil })
 	}
-	keyPEM, err := os.ReadFile(l.options.Key)
+
+	var bundlePEM, certPEM, keyPEM []byte
+	switch {
+	case s.options.PKCS12 != "":
+		var password []byte
+		if passphrase != nil {
+			if password, err = passphrase.Passphrase(); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrDecodePKCS12, err)
+			}
+		}
+		bundlePEM, certPEM, keyPEM, err = decodePKCS12(files[s.options.PKCS12], string(password))
+		if err != nil {
+			return nil, err
+		}
+	case s.options.CombinedPEM != "":
+		bundlePEM, certPEM, keyPEM, err = splitCombinedPEM(files[s.options.CombinedPEM], s.options.CAInCombined)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		certPEM, keyPEM = files[s.options.Certificate], files[s.options.Key]
+	}
+	if s.options.CABundle != "" {
+		bundlePEM = files[s.options.CABundle]
+	}
+	keyPEM, err = decryptPrivateKeyPEM(keyPEM, passphrase)
 	if err != nil {
-		return nil, fmt.Errorf("read key from local file: %w", err)
+		return nil, err
 	}
 	return NewTLSKeyPairRaw(bundlePEM, certPEM, keyPEM), nil
 }
@@ -1,8 +1,12 @@
 package mtls
 
 import (
+	"bytes"
 	"context"
 	"crypto/x509"
+	"log/slog"
+	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -123,6 +127,244 @@ func TestLocalFileTLSConfigLoader_StartLoop(t *testing.T) {
 			loader.KeyPair().Certificate.Leaf.Subject.CommonName,
 		)
 
+		cancel()
+		require.NoError(t, <-errC)
+	})
+	t.Run("it should reload certificate on file events without waiting for the reload interval", func(t *testing.T) {
+		t.Parallel()
+		fs := MustTempKeyPairFiles()
+		defer fs.Close()
+
+		const (
+			FirstKeyPairName  = "first-key-pair"
+			SecondKeyPairName = "second-key-pair"
+		)
+		var (
+			ca      = fakeCA(fakeCATemplate())
+			keyPair = ca.Sign(fakeServerTemplate(func(template *x509.Certificate) {
+				template.Subject.CommonName = FirstKeyPairName
+			}))
+		)
+		fs.Save(ca, keyPair)
+
+		loader, err := NewLocalFileTLSConfigLoader(LocalFileTLSConfigLoaderOptions{
+			CABundle:       fs.CA.Name(),
+			Certificate:    fs.Certificate.Name(),
+			Key:            fs.Key.Name(),
+			ReloadInterval: 1 * time.Hour,
+			Validate:       ValidateKeyPairForServerUsage,
+		})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		errC := make(chan error, 1)
+		go func() {
+			errC <- loader.StartLoop(ctx)
+		}()
+
+		// Replace the files atomically, the way most tools do it.
+		newKeyPair := ca.Sign(fakeServerTemplate(func(template *x509.Certificate) {
+			template.Subject.CommonName = SecondKeyPairName
+		}))
+		replace := func(path string, content []byte) {
+			tmp := path + ".tmp"
+			require.NoError(t, os.WriteFile(tmp, content, 0600))
+			require.NoError(t, os.Rename(tmp, path))
+		}
+		replace(fs.Key.Name(), ToPrivateKeyPEM(newKeyPair.Certificate.PrivateKey))
+		replace(fs.Certificate.Name(), ToCertificatePEM(newKeyPair.Certificate.Leaf.Raw))
+
+		assert.Eventually(t, func() bool {
+			return loader.KeyPair().Certificate.Leaf.Subject.CommonName == SecondKeyPairName
+		}, 2*time.Second, 10*time.Millisecond)
+
+		cancel()
+		require.NoError(t, <-errC)
+	})
+}
+
+func TestLocalFileSource_KeyPairChanged(t *testing.T) {
+	t.Parallel()
+	fs := MustTempKeyPairFiles()
+	defer fs.Close()
+
+	var (
+		ca      = fakeCA(fakeCATemplate())
+		keyPair = ca.Sign(fakeServerTemplate())
+	)
+	fs.Save(ca, keyPair)
+
+	loader, err := NewLocalFileTLSConfigLoader(LocalFileTLSConfigLoaderOptions{
+		CABundle:    fs.CA.Name(),
+		Certificate: fs.Certificate.Name(),
+		Key:         fs.Key.Name(),
+		Validate:    ValidateKeyPairForServerUsage,
+	})
+	require.NoError(t, err)
+	assert.False(t, loader.source.KeyPairChanged())
+
+	require.NoError(t, os.Chtimes(fs.Certificate.Name(), time.Now(), time.Now().Add(time.Minute)))
+	assert.True(t, loader.source.KeyPairChanged())
+
+	require.NoError(t, loader.loader.loadKeyPair(context.Background()))
+	assert.False(t, loader.source.KeyPairChanged())
+}
+
+func TestLocalFileTLSConfigLoader_Watch(t *testing.T) {
+	t.Parallel()
+	fs := MustTempKeyPairFiles()
+	defer fs.Close()
+
+	var (
+		ca      = fakeCA(fakeCATemplate())
+		keyPair = ca.Sign(fakeServerTemplate())
+	)
+	fs.Save(ca, keyPair)
+
+	loader, err := NewLocalFileTLSConfigLoader(LocalFileTLSConfigLoaderOptions{
+		CABundle:    fs.CA.Name(),
+		Certificate: fs.Certificate.Name(),
+		Key:         fs.Key.Name(),
+		Validate:    ValidateKeyPairForServerUsage,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := loader.Watch(ctx)
+
+	t.Log("a valid rotation is reported as a new generation")
+	newKeyPair := ca.Sign(fakeServerTemplate())
+	fs.Save(ca, newKeyPair)
+	require.NoError(t, loader.loader.loadKeyPair(context.Background()))
+
+	event := <-events
+	assert.True(t, event.Succeeded())
+	assert.Equal(t, uint64(2), event.Generation)
+	assert.Equal(t, keyPair.Certificate.Leaf.Raw, event.Previous.Certificate.Leaf.Raw)
+	assert.Equal(t, newKeyPair.Certificate.Leaf.Raw, event.Current.Certificate.Leaf.Raw)
+	assert.Nil(t, event.Rejected)
+
+	t.Log("an unchanged key pair is not reported")
+	require.NoError(t, loader.loader.loadKeyPair(context.Background()))
+	assert.Empty(t, events)
+
+	t.Log("a key pair rejected by Validate is reported with the candidate")
+	clientKeyPair := ca.Sign(fakeClientTemplate())
+	fs.Save(ca, clientKeyPair)
+	require.Error(t, loader.loader.loadKeyPair(context.Background()))
+
+	event = <-events
+	assert.False(t, event.Succeeded())
+	assert.ErrorIs(t, event.Err, ErrValidateKeyPair)
+	assert.Equal(t, uint64(2), event.Generation)
+	assert.Equal(t, newKeyPair.Certificate.Leaf.Raw, event.Current.Certificate.Leaf.Raw)
+	require.NotNil(t, event.Rejected)
+	assert.Equal(t, clientKeyPair.Certificate.Leaf.Raw, event.Rejected.Certificate.Leaf.Raw)
+}
+
+func TestLocalFileTLSConfigLoader_StartLoopErrors(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca      = fakeCA(fakeCATemplate())
+		keyPair = ca.Sign(fakeServerTemplate())
+	)
+
+	t.Run("it should report errors and give up after too many consecutive failures", func(t *testing.T) {
+		t.Parallel()
+		fs := MustTempKeyPairFiles()
+		defer fs.Close()
+		fs.Save(ca, keyPair)
+
+		var (
+			mu     sync.Mutex
+			errs   []error
+			logBuf bytes.Buffer
+		)
+		loader, err := NewLocalFileTLSConfigLoader(LocalFileTLSConfigLoaderOptions{
+			CABundle:       fs.CA.Name(),
+			Certificate:    fs.Certificate.Name(),
+			Key:            fs.Key.Name(),
+			ReloadInterval: 1 * time.Hour,
+			Validate:       ValidateKeyPairForServerUsage,
+			Logger:         slog.New(slog.NewTextHandler(&logBuf, nil)),
+			OnError: func(err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				errs = append(errs, err)
+			},
+			MaxConsecutiveFailures: 3,
+			RetryBackoff:           10 * time.Millisecond,
+			MaxRetryBackoff:        20 * time.Millisecond,
+		})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		errC := make(chan error, 1)
+		go func() {
+			errC <- loader.StartLoop(ctx)
+		}()
+
+		require.NoError(t, os.WriteFile(fs.Certificate.Name(), []byte("corrupted"), 0600))
+
+		err = <-errC
+		require.ErrorIs(t, err, ErrTooManyReloadFailures)
+		assert.ErrorContains(t, err, "parse key pair")
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Len(t, errs, 3)
+		assert.Contains(t, logBuf.String(), "Failed to reload key pair")
+		assert.Contains(t, logBuf.String(), "Giving up reloading key pair")
+
+		// The last good key pair stays in use.
+		assert.Equal(t, keyPair.Certificate.Leaf.Raw, loader.KeyPair().Certificate.Leaf.Raw)
+	})
+
+	t.Run("it should recover once the files are fixed", func(t *testing.T) {
+		t.Parallel()
+		fs := MustTempKeyPairFiles()
+		defer fs.Close()
+		fs.Save(ca, keyPair)
+
+		loader, err := NewLocalFileTLSConfigLoader(LocalFileTLSConfigLoaderOptions{
+			CABundle:        fs.CA.Name(),
+			Certificate:     fs.Certificate.Name(),
+			Key:             fs.Key.Name(),
+			ReloadInterval:  1 * time.Hour,
+			Validate:        ValidateKeyPairForServerUsage,
+			Logger:          slog.New(slog.DiscardHandler),
+			RetryBackoff:    10 * time.Millisecond,
+			MaxRetryBackoff: 20 * time.Millisecond,
+		})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		events := loader.Watch(ctx)
+		errC := make(chan error, 1)
+		go func() {
+			errC <- loader.StartLoop(ctx)
+		}()
+
+		require.NoError(t, os.WriteFile(fs.Certificate.Name(), []byte("corrupted"), 0600))
+		event := <-events
+		require.False(t, event.Succeeded())
+
+		newKeyPair := ca.Sign(fakeServerTemplate())
+		require.NoError(t, os.WriteFile(fs.Key.Name(), ToPrivateKeyPEM(newKeyPair.Certificate.PrivateKey), 0600))
+		require.NoError(t, os.WriteFile(fs.Certificate.Name(), ToCertificatePEM(newKeyPair.Certificate.Leaf.Raw), 0600))
+
+		assert.Eventually(t, func() bool {
+			return bytes.Equal(newKeyPair.Certificate.Leaf.Raw, loader.KeyPair().Certificate.Leaf.Raw)
+		}, 2*time.Second, 10*time.Millisecond)
+
 		cancel()
 		require.NoError(t, <-errC)
 	})
@@ -1,29 +1,11 @@
 package mtls
 
-import (
-	"context"
-	"crypto/tls"
-)
-
-type LocalFileServerTLSConfigLoader struct {
-	loader *LocalFileTLSConfigLoader
-}
-
-func NewLocalFileServerTLSConfigLoader(options LocalFileTLSConfigLoaderOptions) (*LocalFileServerTLSConfigLoader, error) {
-	if options.Validate == nil {
-		options.Validate = ValidateKeyPairForServerUsage
-	}
-	loader, err := NewLocalFileTLSConfigLoader(options)
+// NewLocalFileServerTLSConfigLoader returns a server loader reading the key pair from
+// local files, which are watched for changes and polled every ReloadInterval.
+func NewLocalFileServerTLSConfigLoader(options LocalFileTLSConfigLoaderOptions) (*SourceServerTLSConfigLoader, error) {
+	source, err := newLocalFileSource(options)
 	if err != nil {
 		return nil, err
 	}
-	return &LocalFileServerTLSConfigLoader{loader: loader}, nil
-}
-
-func (l *LocalFileServerTLSConfigLoader) StartLoop(ctx context.Context) error {
-	return l.loader.StartLoop(ctx)
-}
-
-func (l *LocalFileServerTLSConfigLoader) ServerTLSConfig() *tls.Config {
-	return CreateTLSConfigForServer(l.loader)
+	return NewSourceServerTLSConfigLoader(source, source.options.sourceOptions())
 }
@@ -0,0 +1,156 @@
+package mtls
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// OCSPClockSkew is the tolerance for the ThisUpdate and NextUpdate times of OCSP responses.
+const OCSPClockSkew = 5 * time.Minute
+
+var (
+	ErrInvalidOCSPResponse = errors.New("invalid OCSP response")
+	ErrMissingOCSPStaple   = errors.New("missing OCSP staple")
+)
+
+// oidTLSFeature is the TLS feature extension of RFC 7633. A certificate listing the
+// status_request feature (5) in it is a must-staple certificate.
+var oidTLSFeature = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+
+const tlsFeatureStatusRequest = 5
+
+func isMustStaple(cert *x509.Certificate) bool {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidTLSFeature) {
+			continue
+		}
+		var features []int
+		if _, err := asn1.Unmarshal(ext.Value, &features); err != nil {
+			return false
+		}
+		for _, feature := range features {
+			if feature == tlsFeatureStatusRequest {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// VerifyPeerOCSPStaple returns a PeerVerifier that validates the OCSP response stapled by
+// the server: it must be signed by the issuer of the leaf, be current, and report the leaf
+// as good. A revoked leaf is rejected with ErrCertificateRevoked. Like the CRL check, the
+// peer is accepted if the staple checks out against any of its verified chains.
+//
+// Servers that don't staple are accepted, unless requireStaple is set or the leaf is a
+// must-staple certificate. A required staple that can't be checked, e.g. without a
+// verified chain or an issuer, is rejected.
+func VerifyPeerOCSPStaple(requireStaple bool) PeerVerifier {
+	return func(state tls.ConnectionState) error {
+		if len(state.VerifiedChains) == 0 {
+			if requireStaple || (len(state.PeerCertificates) > 0 && isMustStaple(state.PeerCertificates[0])) {
+				return fmt.Errorf("%w: no verified chain to check it against", ErrMissingOCSPStaple)
+			}
+			return nil
+		}
+		var err error
+		for _, chain := range state.VerifiedChains {
+			if err = checkOCSPStaple(state.OCSPResponse, chain, requireStaple); err == nil {
+				return nil
+			}
+		}
+		return err
+	}
+}
+
+// checkOCSPStaple checks the stapled response against the leaf and issuer of the chain.
+func checkOCSPStaple(staple []byte, chain []*x509.Certificate, requireStaple bool) error {
+	leaf := chain[0]
+	required := requireStaple || isMustStaple(leaf)
+	if len(staple) == 0 {
+		if required {
+			return fmt.Errorf("%w: %s", ErrMissingOCSPStaple, leaf.Subject)
+		}
+		return nil
+	}
+	if len(chain) < 2 {
+		if required {
+			return fmt.Errorf("%w: no issuer of %s to check it against", ErrMissingOCSPStaple, leaf.Subject)
+		}
+		return nil // Nothing to check the staple against.
+	}
+	issuer := chain[1]
+	resp, err := parseOCSPResponse(staple, leaf, issuer, time.Now())
+	if err != nil {
+		return err
+	}
+	if resp.Status == ocsp.Revoked {
+		return fmt.Errorf("%w: serial %s of %s at %s",
+			ErrCertificateRevoked, formatSerial(leaf.SerialNumber), leaf.Subject, resp.RevokedAt)
+	}
+	if resp.Status != ocsp.Good {
+		return fmt.Errorf("%w: status of %s is unknown", ErrInvalidOCSPResponse, leaf.Subject)
+	}
+	return nil
+}
+
+// parseOCSPResponse parses the response for the leaf, and checks its signature and
+// validity period. Responses signed by a delegated responder are only trusted if the
+// responder certificate is issued for OCSP signing (RFC 6960 section 4.2.2.2), so that
+// other certificates of the issuer can't vouch for any serial.
+func parseOCSPResponse(der []byte, leaf, issuer *x509.Certificate, now time.Time) (*ocsp.Response, error) {
+	resp, err := ocsp.ParseResponseForCert(der, leaf, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidOCSPResponse, err)
+	}
+	if resp.Certificate != nil && !bytes.Equal(resp.Certificate.Raw, issuer.Raw) &&
+		!slices.Contains(resp.Certificate.ExtKeyUsage, x509.ExtKeyUsageOCSPSigning) {
+		return nil, fmt.Errorf("%w: responder certificate %s is not authorized for OCSP signing",
+			ErrInvalidOCSPResponse, resp.Certificate.Subject)
+	}
+	if resp.ThisUpdate.After(now.Add(OCSPClockSkew)) {
+		return nil, fmt.Errorf("%w: not valid until %s", ErrInvalidOCSPResponse, resp.ThisUpdate)
+	}
+	if !resp.NextUpdate.IsZero() && resp.NextUpdate.Before(now.Add(-OCSPClockSkew)) {
+		return nil, fmt.Errorf("%w: expired at %s", ErrInvalidOCSPResponse, resp.NextUpdate)
+	}
+	return resp, nil
+}
+
+// fetchOCSPResponse asks the first OCSP responder listed in the leaf for its status.
+func fetchOCSPResponse(ctx context.Context, client *http.Client, leaf, issuer *x509.Certificate) ([]byte, error) {
+	if len(leaf.OCSPServer) == 0 {
+		return nil, fmt.Errorf("certificate %s lists no OCSP responder", leaf.Subject)
+	}
+	request, err := ocsp.CreateRequest(leaf, issuer, &ocsp.RequestOptions{Hash: crypto.SHA1})
+	if err != nil {
+		return nil, fmt.Errorf("create OCSP request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, leaf.OCSPServer[0], bytes.NewReader(request))
+	if err != nil {
+		return nil, fmt.Errorf("create OCSP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send OCSP request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("send OCSP request: %s responded %s", leaf.OCSPServer[0], resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
@@ -0,0 +1,180 @@
+package mtls
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+var (
+	DefaultOCSPRefreshInterval = 1 * time.Hour
+	DefaultOCSPRequestTimeout  = 10 * time.Second
+)
+
+type OCSPStaplingOptions struct {
+	ResponseFile    string        // Path to a DER encoded OCSP response to staple; fetched from the responder of the leaf if empty
+	HTTPClient      *http.Client  // Client for the OCSP responder; defaults to http.DefaultClient
+	RefreshInterval time.Duration // Upper bound between refreshes; responses are also refreshed halfway to their NextUpdate
+	Logger          *slog.Logger  // Logger for refresh outcomes; defaults to slog.Default()
+}
+
+func (opts *OCSPStaplingOptions) defaults() {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.RefreshInterval == 0 {
+		opts.RefreshInterval = DefaultOCSPRefreshInterval
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+}
+
+// ocspStapler attaches an OCSP response for the leaf to the key pairs of a loader.
+// It serves the key pairs of the loader itself until a response is obtained, and whenever
+// the latest response for the current leaf has expired.
+type ocspStapler struct {
+	loader interface {
+		KeyPair() *TLSKeyPair
+		Watch(ctx context.Context) <-chan ReloadEvent
+	}
+	options OCSPStaplingOptions
+	stapled atomic.Pointer[stapledKeyPair]
+}
+
+type stapledKeyPair struct {
+	source     *TLSKeyPair // source is the key pair of the loader
+	keyPair    *TLSKeyPair // keyPair is a copy of source with the OCSP staple attached
+	nextUpdate time.Time
+}
+
+func newOCSPStapler(
+	loader interface {
+		KeyPair() *TLSKeyPair
+		Watch(ctx context.Context) <-chan ReloadEvent
+	},
+	options OCSPStaplingOptions,
+) *ocspStapler {
+	options.defaults()
+	return &ocspStapler{loader: loader, options: options}
+}
+
+func (s *ocspStapler) KeyPair() *TLSKeyPair {
+	current := s.loader.KeyPair()
+	stapled := s.stapled.Load()
+	if stapled == nil || stapled.source != current {
+		return current
+	}
+	if !stapled.nextUpdate.IsZero() && time.Now().After(stapled.nextUpdate) {
+		return current // Stapling an expired response would get the handshake rejected.
+	}
+	return stapled.keyPair
+}
+
+// Run keeps the staple up to date until the context is cancelled. The staple is refreshed
+// right away when the key pair changes, halfway to its NextUpdate, and at least every
+// RefreshInterval. Failed refreshes are retried with exponential backoff.
+func (s *ocspStapler) Run(ctx context.Context) {
+	events := s.loader.Watch(ctx)
+	var failures int
+	for {
+		delay, err := s.refresh(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			failures++
+			delay = retryBackoff(failures, DefaultRetryBackoff, min(DefaultMaxRetryBackoff, s.options.RefreshInterval))
+			s.options.Logger.Error("Failed to refresh OCSP staple",
+				slog.String("error", err.Error()),
+				slog.Int("consecutive-failures", failures),
+				slog.Duration("retry-in", delay),
+			)
+		} else {
+			failures = 0
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-events:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// refresh obtains a response for the current leaf, and returns when to refresh it next.
+func (s *ocspStapler) refresh(ctx context.Context) (time.Duration, error) {
+	source := s.loader.KeyPair()
+	leaf := source.Certificate.Leaf
+	issuer, err := leafIssuer(source)
+	if err != nil {
+		return 0, err
+	}
+
+	var der []byte
+	if s.options.ResponseFile != "" {
+		der, err = os.ReadFile(s.options.ResponseFile)
+	} else {
+		reqCtx, cancel := context.WithTimeout(ctx, DefaultOCSPRequestTimeout)
+		der, err = fetchOCSPResponse(reqCtx, s.options.HTTPClient, leaf, issuer)
+		cancel()
+	}
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	resp, err := parseOCSPResponse(der, leaf, issuer, now)
+	if err != nil {
+		return 0, err
+	}
+	switch resp.Status {
+	case ocsp.Good:
+	case ocsp.Revoked:
+		s.stapled.Store(nil) // Stop serving a staple that no longer holds.
+		return 0, fmt.Errorf("%w: serial %s of %s at %s", ErrCertificateRevoked, formatSerial(leaf.SerialNumber), leaf.Subject, resp.RevokedAt)
+	default:
+		s.stapled.Store(nil)
+		return 0, fmt.Errorf("OCSP responder doesn't know the status of %s", leaf.Subject)
+	}
+
+	cert := *source.Certificate
+	cert.OCSPStaple = der
+	keyPair := *source
+	keyPair.Certificate = &cert
+	s.stapled.Store(&stapledKeyPair{source: source, keyPair: &keyPair, nextUpdate: resp.NextUpdate})
+
+	delay := s.options.RefreshInterval
+	if !resp.NextUpdate.IsZero() {
+		delay = min(delay, resp.ThisUpdate.Add(resp.NextUpdate.Sub(resp.ThisUpdate)/2).Sub(now))
+	}
+	s.options.Logger.Info("Refreshed OCSP staple",
+		slog.String("subject", leaf.Subject.String()),
+		slog.Time("next-update", resp.NextUpdate),
+		slog.Duration("refresh-in", delay),
+	)
+	return max(delay, time.Second), nil
+}
+
+// leafIssuer returns the certificate that issued the leaf, from the chain of the key pair
+// or its CA bundle.
+func leafIssuer(keyPair *TLSKeyPair) (*x509.Certificate, error) {
+	chains, err := verifyCertificateChain(keyPair, x509.ExtKeyUsageAny)
+	if err != nil {
+		return nil, fmt.Errorf("find issuer of the leaf: %w", err)
+	}
+	if len(chains[0]) < 2 {
+		return nil, fmt.Errorf("find issuer of the leaf: the leaf is self-signed")
+	}
+	return chains[0][1], nil
+}
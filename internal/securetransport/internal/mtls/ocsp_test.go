@@ -0,0 +1,179 @@
+package mtls
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+)
+
+func withMustStaple() func(template *x509.Certificate) {
+	return func(template *x509.Certificate) {
+		value, err := asn1.Marshal([]int{tlsFeatureStatusRequest})
+		PanicIfErr(err)
+		template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{Id: oidTLSFeature, Value: value})
+	}
+}
+
+func TestVerifyPeerOCSPStaple(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca         = fakeCA(fakeCATemplate())
+		otherCA    = fakeCA(fakeCATemplate())
+		leaf       = ca.Sign(fakeServerTemplate(withSerial(1))).Certificate.Leaf
+		mustStaple = ca.Sign(fakeServerTemplate(withSerial(2), withMustStaple())).Certificate.Leaf
+		nextUpdate = time.Now().Add(time.Hour)
+		state      = func(leaf *x509.Certificate, staple []byte) tls.ConnectionState {
+			return tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{leaf},
+				VerifiedChains:   [][]*x509.Certificate{{leaf, ca.Certificate}},
+				OCSPResponse:     staple,
+			}
+		}
+	)
+
+	t.Run("it should accept a good staple", func(t *testing.T) {
+		t.Parallel()
+		staple := ca.OCSPResponse(leaf, ocsp.Good, nextUpdate)
+		assert.NoError(t, VerifyPeerOCSPStaple(true)(state(leaf, staple)))
+	})
+
+	t.Run("it should reject a revoked staple", func(t *testing.T) {
+		t.Parallel()
+		staple := ca.OCSPResponse(leaf, ocsp.Revoked, nextUpdate)
+		assert.ErrorIs(t, VerifyPeerOCSPStaple(false)(state(leaf, staple)), ErrCertificateRevoked)
+	})
+
+	t.Run("it should reject untrusted, expired and unknown staples", func(t *testing.T) {
+		t.Parallel()
+		verify := VerifyPeerOCSPStaple(false)
+		assert.ErrorIs(t, verify(state(leaf, otherCA.OCSPResponse(leaf, ocsp.Good, nextUpdate))), ErrInvalidOCSPResponse)
+		assert.ErrorIs(t, verify(state(leaf, ca.OCSPResponse(leaf, ocsp.Good, time.Now().Add(-time.Hour)))), ErrInvalidOCSPResponse)
+		assert.ErrorIs(t, verify(state(leaf, ca.OCSPResponse(mustStaple, ocsp.Good, nextUpdate))), ErrInvalidOCSPResponse)
+		assert.ErrorIs(t, verify(state(leaf, ca.OCSPResponse(leaf, ocsp.Unknown, nextUpdate))), ErrInvalidOCSPResponse)
+	})
+
+	t.Run("it should only trust delegated responders issued for OCSP signing", func(t *testing.T) {
+		t.Parallel()
+		delegated := func(extKeyUsage ...x509.ExtKeyUsage) []byte {
+			responder := ca.Sign(fakeClientTemplate(func(template *x509.Certificate) {
+				template.Subject.CommonName = "test-responder"
+				template.ExtKeyUsage = extKeyUsage
+			})).Certificate
+			der, err := ocsp.CreateResponse(ca.Certificate, responder.Leaf, ocsp.Response{
+				Status:       ocsp.Good,
+				SerialNumber: leaf.SerialNumber,
+				ThisUpdate:   time.Now().Add(-time.Minute),
+				NextUpdate:   nextUpdate,
+				Certificate:  responder.Leaf,
+			}, responder.PrivateKey.(crypto.Signer))
+			PanicIfErr(err)
+			return der
+		}
+		verify := VerifyPeerOCSPStaple(false)
+		assert.NoError(t, verify(state(leaf, delegated(x509.ExtKeyUsageOCSPSigning))))
+		err := verify(state(leaf, delegated(x509.ExtKeyUsageServerAuth)))
+		assert.ErrorIs(t, err, ErrInvalidOCSPResponse)
+		assert.ErrorContains(t, err, "not authorized for OCSP signing")
+	})
+
+	t.Run("it should only require a staple when asked to or for must-staple certificates", func(t *testing.T) {
+		t.Parallel()
+		assert.NoError(t, VerifyPeerOCSPStaple(false)(state(leaf, nil)))
+		assert.ErrorIs(t, VerifyPeerOCSPStaple(true)(state(leaf, nil)), ErrMissingOCSPStaple)
+		assert.ErrorIs(t, VerifyPeerOCSPStaple(false)(state(mustStaple, nil)), ErrMissingOCSPStaple)
+	})
+
+	t.Run("it should accept the staple if it checks out against any verified chain", func(t *testing.T) {
+		t.Parallel()
+		staple := ca.OCSPResponse(leaf, ocsp.Good, nextUpdate)
+		multi := state(leaf, staple)
+		multi.VerifiedChains = [][]*x509.Certificate{{leaf, otherCA.Certificate}, {leaf, ca.Certificate}}
+		assert.NoError(t, VerifyPeerOCSPStaple(true)(multi))
+
+		multi.VerifiedChains = [][]*x509.Certificate{{leaf, otherCA.Certificate}}
+		assert.ErrorIs(t, VerifyPeerOCSPStaple(true)(multi), ErrInvalidOCSPResponse)
+	})
+
+	t.Run("it should reject a required staple that can't be checked", func(t *testing.T) {
+		t.Parallel()
+		staple := ca.OCSPResponse(leaf, ocsp.Good, nextUpdate)
+		short := state(leaf, staple)
+		short.VerifiedChains = [][]*x509.Certificate{{leaf}}
+		assert.NoError(t, VerifyPeerOCSPStaple(false)(short))
+		assert.ErrorIs(t, VerifyPeerOCSPStaple(true)(short), ErrMissingOCSPStaple)
+
+		unverified := state(leaf, staple)
+		unverified.VerifiedChains = nil
+		assert.NoError(t, VerifyPeerOCSPStaple(false)(unverified))
+		assert.ErrorIs(t, VerifyPeerOCSPStaple(true)(unverified), ErrMissingOCSPStaple)
+	})
+}
+
+func TestOCSPStapling(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca        = fakeCA(fakeCATemplate())
+		responder = newFakeOCSPResponder(ca)
+		serverKP  = ca.Sign(fakeServerTemplate(func(template *x509.Certificate) {
+			template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+			template.OCSPServer = []string{responder.URL}
+		}, withSerial(10)))
+		clientKP = ca.Sign(fakeClientTemplate())
+	)
+	defer responder.Close()
+
+	loader, err := NewSourceServerTLSConfigLoader(&fakeKeyPairSource{raw: serverKP.Raw}, SourceTLSConfigLoaderOptions{
+		ReloadInterval: time.Hour,
+		OCSPStapling:   &OCSPStaplingOptions{RefreshInterval: 50 * time.Millisecond},
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = loader.StartLoop(ctx) }()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = loader.ServerTLSConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	get := func() error {
+		client := http.Client{
+			Transport: CreateDynamicTLSTransport(&fakeKeyPairLoader{keyPair: clientKP}, VerifyPeerOCSPStaple(true)),
+		}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	t.Log("the server staples a good response fetched from the responder")
+	require.Eventually(t, func() bool { return get() == nil }, 2*time.Second, 20*time.Millisecond)
+	assert.NotEmpty(t, loader.stapler.KeyPair().Certificate.OCSPStaple)
+	assert.Empty(t, serverKP.Certificate.OCSPStaple, "the key pair of the loader must not be modified")
+
+	t.Log("the server stops stapling once the responder reports the revocation")
+	responder.SetStatus(big.NewInt(10), ocsp.Revoked)
+	require.Eventually(t, func() bool {
+		return errors.Is(get(), ErrMissingOCSPStaple)
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.Empty(t, loader.stapler.KeyPair().Certificate.OCSPStaple)
+}
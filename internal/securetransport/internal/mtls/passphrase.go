@@ -0,0 +1,111 @@
+package mtls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/youmark/pkcs8"
+)
+
+var ErrDecryptPrivateKey = errors.New("decrypt private key")
+
+// PassphraseProvider returns the passphrase of an encrypted private key or PKCS#12 keystore.
+// It is asked on every reload, so that passphrase rotations are picked up.
+type PassphraseProvider interface {
+	Passphrase() ([]byte, error)
+}
+
+// PassphraseFunc adapts a function to a PassphraseProvider.
+type PassphraseFunc func() ([]byte, error)
+
+func (f PassphraseFunc) Passphrase() ([]byte, error) {
+	return f()
+}
+
+// PassphraseFromFile reads the passphrase from a file, ignoring a trailing newline.
+// Local file loaders read and watch the file along with the key pair, so that the
+// passphrase comes from the same generation as the key, and a rotated passphrase is
+// picked up even if the key files don't change.
+func PassphraseFromFile(path string) PassphraseProvider {
+	return passphraseFile(path)
+}
+
+type passphraseFile string
+
+func (f passphraseFile) Passphrase() ([]byte, error) {
+	content, err := os.ReadFile(string(f))
+	if err != nil {
+		return nil, fmt.Errorf("read passphrase file: %w", err)
+	}
+	return trimPassphrase(content), nil
+}
+
+func trimPassphrase(content []byte) []byte {
+	return bytes.TrimRight(content, "\r\n")
+}
+
+// passphrasePath returns the file of a PassphraseFromFile provider, or "" for any other.
+func passphrasePath(passphrase PassphraseProvider) string {
+	if file, ok := passphrase.(passphraseFile); ok {
+		return string(file)
+	}
+	return ""
+}
+
+// PassphraseFromEnv reads the passphrase from an environment variable.
+func PassphraseFromEnv(name string) PassphraseProvider {
+	return PassphraseFunc(func() ([]byte, error) {
+		passphrase, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("passphrase environment variable %s is not set", name)
+		}
+		return []byte(passphrase), nil
+	})
+}
+
+// decryptPrivateKeyPEM returns the key as an unencrypted PEM block.
+//
+// Both encrypted PKCS#8 (`ENCRYPTED PRIVATE KEY`) and legacy OpenSSL encrypted PEM
+// (`Proc-Type: 4,ENCRYPTED`) keys are decrypted with the passphrase from the provider.
+// Unencrypted keys are returned as they are, without calling the provider.
+func decryptPrivateKeyPEM(keyPEM []byte, passphrase PassphraseProvider) ([]byte, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return keyPEM, nil // Let the parser report the malformed key.
+	}
+	encrypted := block.Type == "ENCRYPTED PRIVATE KEY"
+	// Legacy encrypted PEM is deprecated as insecure by design, but still produced by some tools.
+	legacy := x509.IsEncryptedPEMBlock(block)
+	if !encrypted && !legacy {
+		return keyPEM, nil
+	}
+	if passphrase == nil {
+		return nil, fmt.Errorf("%w: the private key is encrypted but no passphrase is configured", ErrDecryptPrivateKey)
+	}
+	secret, err := passphrase.Passphrase()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecryptPrivateKey, err)
+	}
+
+	if legacy {
+		der, err := x509.DecryptPEMBlock(block, secret)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrDecryptPrivateKey, err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}), nil
+	}
+
+	key, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, secret)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecryptPrivateKey, err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: marshal private key: %w", ErrDecryptPrivateKey, err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,218 @@
+package mtls
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/youmark/pkcs8"
+)
+
+func ToEncryptedPrivateKeyPEM(privateKey any, passphrase string) []byte {
+	der, err := pkcs8.MarshalPrivateKey(privateKey, []byte(passphrase), nil)
+	PanicIfErr(err)
+	return pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der})
+}
+
+func TestDecryptPrivateKeyPEM(t *testing.T) {
+	t.Parallel()
+
+	const Passphrase = "s3cret"
+
+	var (
+		keyPair = fakeCA(fakeCATemplate()).Sign(fakeServerTemplate())
+		keyPEM  = ToPrivateKeyPEM(keyPair.Certificate.PrivateKey)
+	)
+
+	legacyBlock, _ := pem.Decode(keyPEM)
+	// Legacy encrypted PEM is deprecated, but still produced by some tools.
+	legacyEncrypted, err := x509.EncryptPEMBlock(rand.Reader, legacyBlock.Type, legacyBlock.Bytes, []byte(Passphrase), x509.PEMCipherAES256)
+	require.NoError(t, err)
+
+	tests := []struct {
+		Name          string
+		KeyPEM        []byte
+		Passphrase    PassphraseProvider
+		ExpectedError string
+	}{
+		{
+			Name:   "unencrypted key",
+			KeyPEM: keyPEM,
+		},
+		{
+			Name:       "encrypted PKCS#8 key",
+			KeyPEM:     ToEncryptedPrivateKeyPEM(keyPair.Certificate.PrivateKey, Passphrase),
+			Passphrase: PassphraseFunc(func() ([]byte, error) { return []byte(Passphrase), nil }),
+		},
+		{
+			Name:       "legacy encrypted PEM key",
+			KeyPEM:     pem.EncodeToMemory(legacyEncrypted),
+			Passphrase: PassphraseFunc(func() ([]byte, error) { return []byte(Passphrase), nil }),
+		},
+		{
+			Name:          "encrypted key without passphrase",
+			KeyPEM:        ToEncryptedPrivateKeyPEM(keyPair.Certificate.PrivateKey, Passphrase),
+			ExpectedError: "no passphrase is configured",
+		},
+		{
+			Name:          "encrypted key with wrong passphrase",
+			KeyPEM:        ToEncryptedPrivateKeyPEM(keyPair.Certificate.PrivateKey, Passphrase),
+			Passphrase:    PassphraseFunc(func() ([]byte, error) { return []byte("wrong"), nil }),
+			ExpectedError: ErrDecryptPrivateKey.Error(),
+		},
+		{
+			Name:          "missing passphrase environment variable",
+			KeyPEM:        ToEncryptedPrivateKeyPEM(keyPair.Certificate.PrivateKey, Passphrase),
+			Passphrase:    PassphraseFromEnv("MTLS_TEST_MISSING_PASSPHRASE"),
+			ExpectedError: "MTLS_TEST_MISSING_PASSPHRASE is not set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+
+			decrypted, err := decryptPrivateKeyPEM(tt.KeyPEM, tt.Passphrase)
+			if tt.ExpectedError != "" {
+				require.ErrorIs(t, err, ErrDecryptPrivateKey)
+				assert.ErrorContains(t, err, tt.ExpectedError)
+				return
+			}
+			require.NoError(t, err)
+			block, _ := pem.Decode(decrypted)
+			require.NotNil(t, block)
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			require.NoError(t, err)
+			assert.Equal(t, keyPair.Certificate.PrivateKey, key)
+		})
+	}
+}
+
+func TestNewLocalFileTLSConfigLoader_EncryptedKey(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca      = fakeCA(fakeCATemplate())
+		keyPair = ca.Sign(fakeServerTemplate())
+	)
+
+	fs := MustTempKeyPairFiles()
+	defer fs.Close()
+	fs.SaveCA(ca)
+	fs.SaveCertificate(keyPair.Certificate)
+
+	passphraseFile := filepath.Join(t.TempDir(), "passphrase")
+	require.NoError(t, os.WriteFile(passphraseFile, []byte("first\n"), 0600))
+	require.NoError(t, os.WriteFile(fs.Key.Name(), ToEncryptedPrivateKeyPEM(keyPair.Certificate.PrivateKey, "first"), 0600))
+
+	loader, err := NewLocalFileTLSConfigLoader(LocalFileTLSConfigLoaderOptions{
+		CABundle:        fs.CA.Name(),
+		Certificate:     fs.Certificate.Name(),
+		Key:             fs.Key.Name(),
+		KeyPassphrase:   PassphraseFromFile(passphraseFile),
+		ReloadInterval:  1 * time.Hour,
+		Validate:        ValidateKeyPairForServerUsage,
+		RetryBackoff:    10 * time.Millisecond,
+		MaxRetryBackoff: 50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, keyPair.Certificate.PrivateKey, loader.KeyPair().Certificate.PrivateKey)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- loader.StartLoop(ctx)
+	}()
+
+	// The key is re-encrypted before the new passphrase is published; the failed
+	// reload is retried until the passphrase catches up.
+	newKeyPair := ca.Sign(fakeServerTemplate())
+	require.NoError(t, os.WriteFile(fs.Certificate.Name(), ToCertificatePEM(newKeyPair.Certificate.Leaf.Raw), 0600))
+	require.NoError(t, os.WriteFile(fs.Key.Name(), ToEncryptedPrivateKeyPEM(newKeyPair.Certificate.PrivateKey, "second"), 0600))
+	time.Sleep(200 * time.Millisecond)
+	require.NoError(t, os.WriteFile(passphraseFile, []byte("second\n"), 0600))
+
+	assert.Eventually(t, func() bool {
+		return string(loader.KeyPair().Certificate.Leaf.Raw) == string(newKeyPair.Certificate.Leaf.Raw)
+	}, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-errC)
+}
+
+func TestNewLocalFileTLSConfigLoader_PassphraseFileRotation(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca      = fakeCA(fakeCATemplate())
+		keyPair = ca.Sign(fakeServerTemplate())
+	)
+
+	fs := MustTempKeyPairFiles()
+	defer fs.Close()
+	fs.SaveCA(ca)
+	fs.SaveCertificate(keyPair.Certificate)
+
+	passphraseFile := filepath.Join(t.TempDir(), "passphrase")
+	require.NoError(t, os.WriteFile(passphraseFile, []byte("first\n"), 0600))
+	require.NoError(t, os.WriteFile(fs.Key.Name(), ToEncryptedPrivateKeyPEM(keyPair.Certificate.PrivateKey, "first"), 0600))
+
+	loader, err := NewLocalFileTLSConfigLoader(LocalFileTLSConfigLoaderOptions{
+		CABundle:      fs.CA.Name(),
+		Certificate:   fs.Certificate.Name(),
+		Key:           fs.Key.Name(),
+		KeyPassphrase: PassphraseFromFile(passphraseFile),
+		// Neither polling nor retries reload the key pair within the test.
+		ReloadInterval: 1 * time.Hour,
+		WatchDebounce:  10 * time.Millisecond,
+		Validate:       ValidateKeyPairForServerUsage,
+		RetryBackoff:   1 * time.Hour,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := loader.Watch(ctx)
+	errC := make(chan error, 1)
+	go func() {
+		errC <- loader.StartLoop(ctx)
+	}()
+
+	waitFor := func(succeeded bool) {
+		for {
+			select {
+			case event := <-events:
+				if event.Succeeded() == succeeded {
+					return
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("no reload with succeeded=%t", succeeded)
+			}
+		}
+	}
+
+	t.Log("the key pair is rotated ahead of the passphrase and fails to load")
+	newKeyPair := ca.Sign(fakeServerTemplate())
+	require.NoError(t, os.WriteFile(fs.Certificate.Name(), ToCertificatePEM(newKeyPair.Certificate.Leaf.Raw), 0600))
+	require.NoError(t, os.WriteFile(fs.Key.Name(), ToEncryptedPrivateKeyPEM(newKeyPair.Certificate.PrivateKey, "second"), 0600))
+	waitFor(false)
+	assert.True(t, loader.KeyPair().Equal(keyPair))
+
+	t.Log("rotating the passphrase file alone reloads the key pair")
+	require.NoError(t, os.WriteFile(passphraseFile, []byte("second\n"), 0600))
+	waitFor(true)
+	assert.True(t, loader.KeyPair().Equal(newKeyPair))
+
+	cancel()
+	require.NoError(t, <-errC)
+}
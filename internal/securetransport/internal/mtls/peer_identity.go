@@ -0,0 +1,100 @@
+package mtls
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// PeerIdentity is what handlers need to know about the verified certificate of a peer.
+type PeerIdentity struct {
+	SPIFFEID        *SPIFFEID         // Nil unless the certificate carries exactly one SPIFFE ID
+	DNSNames        []string          // DNS SANs
+	CommonName      string            // Subject common name
+	SerialNumber    string            // Serial number in lowercase hex, as in the logs and errors of the package
+	Issuer          string            // Distinguished name of the issuer
+	SPKIFingerprint string            // Hex encoded SHA-256 of the SubjectPublicKeyInfo, as in PeerPolicyRule.SPKISHA256
+	NotAfter        time.Time         // Expiry of the certificate
+	Certificate     *x509.Certificate // Leaf certificate of the peer
+}
+
+// NewPeerIdentity returns the identity of a peer certificate.
+func NewPeerIdentity(cert *x509.Certificate) *PeerIdentity {
+	fingerprint := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	rv := &PeerIdentity{
+		DNSNames:        cert.DNSNames,
+		CommonName:      cert.Subject.CommonName,
+		SerialNumber:    formatSerial(cert.SerialNumber),
+		Issuer:          cert.Issuer.String(),
+		SPKIFingerprint: hex.EncodeToString(fingerprint[:]),
+		NotAfter:        cert.NotAfter,
+		Certificate:     cert,
+	}
+	if id, err := certificateSPIFFEID(cert); err == nil {
+		rv.SPIFFEID = &id
+	}
+	return rv
+}
+
+// formatSerial formats a certificate serial number in lowercase hex; `openssl x509 -serial`
+// prints the same digits in uppercase. The issuer command takes either with a 0x prefix.
+func formatSerial(serial *big.Int) string {
+	return serial.Text(16)
+}
+
+// PeerIdentityFromConnectionState returns the identity of the peer of a connection, if it
+// presented a certificate that was verified.
+func PeerIdentityFromConnectionState(state tls.ConnectionState) (*PeerIdentity, bool) {
+	if len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+		return nil, false
+	}
+	return NewPeerIdentity(state.PeerCertificates[0]), true
+}
+
+// LogValue logs the identity as a group, e.g. slog.Any("peer", identity).
+func (id *PeerIdentity) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("common-name", id.CommonName),
+		slog.String("serial-number", id.SerialNumber),
+		slog.String("issuer", id.Issuer),
+		slog.String("spki-sha256", id.SPKIFingerprint),
+		slog.Time("not-after", id.NotAfter),
+	}
+	if id.SPIFFEID != nil {
+		attrs = append(attrs, slog.String("spiffe-id", id.SPIFFEID.String()))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+type peerIdentityKey struct{}
+
+// ContextWithPeerIdentity returns a copy of the context carrying the identity.
+func ContextWithPeerIdentity(ctx context.Context, id *PeerIdentity) context.Context {
+	return context.WithValue(ctx, peerIdentityKey{}, id)
+}
+
+// PeerIdentityFromContext returns the identity put in the context by PeerIdentityMiddleware
+// or ContextWithPeerIdentity.
+func PeerIdentityFromContext(ctx context.Context) (*PeerIdentity, bool) {
+	id, ok := ctx.Value(peerIdentityKey{}).(*PeerIdentity)
+	return id, ok && id != nil
+}
+
+// PeerIdentityMiddleware puts the identity of the client in the request context, if the
+// client presented a verified certificate. Requests without one are passed on unchanged.
+func PeerIdentityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			if id, ok := PeerIdentityFromConnectionState(*r.TLS); ok {
+				r = r.WithContext(ContextWithPeerIdentity(r.Context(), id))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,101 @@
+package mtls
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPeerIdentity(t *testing.T) {
+	t.Parallel()
+
+	ca := fakeCA(fakeCATemplate())
+
+	t.Run("it should extract the identity of the certificate", func(t *testing.T) {
+		t.Parallel()
+
+		cert := ca.Sign(fakeClientTemplate(func(template *x509.Certificate) {
+			template.Subject.CommonName = "test-client"
+			template.DNSNames = []string{"client.internal"}
+			template.URIs = []*url.URL{{Scheme: "spiffe", Host: "example.org", Path: "/ns/default/sa/client"}}
+		})).Certificate.Leaf
+		fingerprint := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+		id := NewPeerIdentity(cert)
+		require.NotNil(t, id.SPIFFEID)
+		assert.Equal(t, "spiffe://example.org/ns/default/sa/client", id.SPIFFEID.String())
+		assert.Equal(t, []string{"client.internal"}, id.DNSNames)
+		assert.Equal(t, "test-client", id.CommonName)
+		assert.Equal(t, cert.SerialNumber.Text(16), id.SerialNumber)
+		assert.Equal(t, ca.Certificate.Subject.String(), id.Issuer)
+		assert.Equal(t, hex.EncodeToString(fingerprint[:]), id.SPKIFingerprint)
+		assert.Equal(t, cert.NotAfter, id.NotAfter)
+		assert.Same(t, cert, id.Certificate)
+	})
+
+	t.Run("it should leave the SPIFFE ID unset if the certificate has none", func(t *testing.T) {
+		t.Parallel()
+
+		id := NewPeerIdentity(ca.Sign(fakeClientTemplate()).Certificate.Leaf)
+		assert.Nil(t, id.SPIFFEID)
+	})
+
+	t.Run("it should only trust verified peers", func(t *testing.T) {
+		t.Parallel()
+
+		cert := ca.Sign(fakeClientTemplate()).Certificate.Leaf
+		_, ok := PeerIdentityFromConnectionState(tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}})
+		assert.False(t, ok)
+		_, ok = PeerIdentityFromContext(context.Background())
+		assert.False(t, ok)
+	})
+}
+
+func TestPeerIdentityMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca            = fakeCA(fakeCATemplate())
+		serverKeyPair = ca.Sign(fakeServerTemplate(func(template *x509.Certificate) {
+			template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		}))
+		clientKeyPair = ca.Sign(fakeClientTemplate(func(template *x509.Certificate) {
+			template.Subject.CommonName = "test-client"
+		}))
+	)
+
+	server := httptest.NewUnstartedServer(PeerIdentityMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := PeerIdentityFromContext(r.Context())
+		if !ok {
+			_, _ = w.Write([]byte("anonymous"))
+			return
+		}
+		_, _ = w.Write([]byte(id.CommonName))
+	})))
+	server.TLS = createTLSConfigForServerLoader(&fakeKeyPairLoader{keyPair: serverKeyPair}, VerifyClientCertIfGiven)
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(client *http.Client) string {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	assert.Equal(t, "test-client", get(&http.Client{Transport: CreateDynamicTLSTransport(&fakeKeyPairLoader{keyPair: clientKeyPair})}))
+	assert.Equal(t, "anonymous", get(&http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: ca.pool()}}}))
+}
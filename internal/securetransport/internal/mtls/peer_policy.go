@@ -0,0 +1,137 @@
+package mtls
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrParsePeerPolicy = errors.New("parse peer policy")
+	ErrPeerNotAllowed  = errors.New("peer is not allowed by policy")
+)
+
+// PeerPolicy is an allowlist of peer certificates. A certificate is allowed if it matches
+// any of the rules. An empty policy allows no one.
+//
+// Policies are JSON documents:
+//
+//	{
+//	  "allow": [
+//	    {"name": "frontend", "uris": ["spiffe://example.org/ns/web/sa/frontend"]},
+//	    {"name": "ops", "commonNames": ["ops-client"], "organizationalUnits": ["platform"]},
+//	    {"name": "legacy", "spkiSHA256": ["2f3b...e1"]}
+//	  ]
+//	}
+type PeerPolicy struct {
+	Allow []PeerPolicyRule `json:"allow"`
+}
+
+// PeerPolicyRule matches a certificate if every criterion that is set matches. A criterion
+// matches if the certificate has any of the listed values.
+type PeerPolicyRule struct {
+	Name                string   `json:"name"`                // Name of the rule in logs
+	CommonNames         []string `json:"commonNames"`         // Subject common names
+	DNSNames            []string `json:"dnsNames"`            // DNS SANs
+	URIs                []string `json:"uris"`                // URI SANs, e.g. SPIFFE IDs
+	OrganizationalUnits []string `json:"organizationalUnits"` // Subject organizational units
+	SPKISHA256          []string `json:"spkiSHA256"`          // SHA-256 of the SubjectPublicKeyInfo, hex or base64 encoded
+
+	spkiFingerprints [][]byte
+}
+
+// ParsePeerPolicy parses a JSON policy. Unknown fields and rules without criteria are
+// rejected, so that a typo can't silently widen or empty a rule.
+func ParsePeerPolicy(data []byte) (*PeerPolicy, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var policy PeerPolicy
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrParsePeerPolicy, err)
+	}
+	for i := range policy.Allow {
+		rule := &policy.Allow[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if len(rule.CommonNames)+len(rule.DNSNames)+len(rule.URIs)+
+			len(rule.OrganizationalUnits)+len(rule.SPKISHA256) == 0 {
+			return nil, fmt.Errorf("%w: rule %s has no criteria", ErrParsePeerPolicy, rule.Name)
+		}
+		for _, fingerprint := range rule.SPKISHA256 {
+			decoded, err := decodeFingerprint(fingerprint)
+			if err != nil {
+				return nil, fmt.Errorf("%w: rule %s: %w", ErrParsePeerPolicy, rule.Name, err)
+			}
+			rule.spkiFingerprints = append(rule.spkiFingerprints, decoded)
+		}
+	}
+	return &policy, nil
+}
+
+func decodeFingerprint(s string) ([]byte, error) {
+	if decoded, err := hex.DecodeString(strings.ReplaceAll(s, ":", "")); err == nil && len(decoded) == sha256.Size {
+		return decoded, nil
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(s); err == nil && len(decoded) == sha256.Size {
+		return decoded, nil
+	}
+	return nil, fmt.Errorf("SPKI fingerprint %q is not a hex or base64 encoded SHA-256", s)
+}
+
+// Authorize returns nil if the certificate matches a rule. Otherwise the error wraps
+// ErrPeerNotAllowed and explains why each rule did not match.
+func (p *PeerPolicy) Authorize(cert *x509.Certificate) error {
+	reasons := make([]string, 0, len(p.Allow))
+	for _, rule := range p.Allow {
+		reason := rule.mismatch(cert)
+		if reason == "" {
+			return nil
+		}
+		reasons = append(reasons, fmt.Sprintf("rule %s: %s", rule.Name, reason))
+	}
+	if len(reasons) == 0 {
+		return fmt.Errorf("%w: the policy has no allow rules", ErrPeerNotAllowed)
+	}
+	return fmt.Errorf("%w: %s", ErrPeerNotAllowed, strings.Join(reasons, "; "))
+}
+
+// mismatch returns why the certificate doesn't match the rule, or an empty string if it does.
+func (r *PeerPolicyRule) mismatch(cert *x509.Certificate) string {
+	if len(r.CommonNames) > 0 && !slices.Contains(r.CommonNames, cert.Subject.CommonName) {
+		return fmt.Sprintf("common name %q is not one of %q", cert.Subject.CommonName, r.CommonNames)
+	}
+	if len(r.DNSNames) > 0 && !containsAny(r.DNSNames, cert.DNSNames) {
+		return fmt.Sprintf("DNS names %q are not any of %q", cert.DNSNames, r.DNSNames)
+	}
+	if len(r.URIs) > 0 {
+		uris := make([]string, 0, len(cert.URIs))
+		for _, uri := range cert.URIs {
+			uris = append(uris, uri.String())
+		}
+		if !containsAny(r.URIs, uris) {
+			return fmt.Sprintf("URIs %q are not any of %q", uris, r.URIs)
+		}
+	}
+	if len(r.OrganizationalUnits) > 0 && !containsAny(r.OrganizationalUnits, cert.Subject.OrganizationalUnit) {
+		return fmt.Sprintf("organizational units %q are not any of %q", cert.Subject.OrganizationalUnit, r.OrganizationalUnits)
+	}
+	if len(r.spkiFingerprints) > 0 {
+		fingerprint := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		if !slices.ContainsFunc(r.spkiFingerprints, func(f []byte) bool { return bytes.Equal(f, fingerprint[:]) }) {
+			return fmt.Sprintf("SPKI fingerprint %s is not allowed", hex.EncodeToString(fingerprint[:]))
+		}
+	}
+	return ""
+}
+
+func containsAny(allowed, values []string) bool {
+	return slices.ContainsFunc(values, func(v string) bool { return slices.Contains(allowed, v) })
+}
@@ -0,0 +1,83 @@
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+)
+
+// PeerPolicyLoaderOptions are the options of a PeerPolicyLoader, where Path is the JSON
+// policy file.
+type PeerPolicyLoaderOptions = FileLoaderOptions
+
+// PeerPolicyReloadEvent describes the outcome of a policy reload attempt that found new
+// content, like ReloadEvent does for key pairs.
+type PeerPolicyReloadEvent = FileReloadEvent[PeerPolicy]
+
+// PeerPolicyLoader keeps a PeerPolicy loaded from a file up to date, and enforces it
+// through VerifyPeer. A policy file that fails to parse is logged and the previous policy
+// stays in use, like a key pair that fails validation.
+type PeerPolicyLoader struct {
+	options PeerPolicyLoaderOptions
+	file    *fileLoader[PeerPolicy]
+}
+
+func NewPeerPolicyLoader(options PeerPolicyLoaderOptions) (*PeerPolicyLoader, error) {
+	if err := checkFile(options.Path); err != nil {
+		return nil, fmt.Errorf("check peer policy file: %w", err)
+	}
+	if err := options.defaults(); err != nil {
+		return nil, err
+	}
+	loader := &PeerPolicyLoader{
+		options: options,
+		file: &fileLoader[PeerPolicy]{
+			name:    "peer policy",
+			options: options,
+			parse: func(_ []string, contents [][]byte) (*PeerPolicy, error) {
+				return ParsePeerPolicy(contents[0])
+			},
+			attrs: func(policy *PeerPolicy) []slog.Attr {
+				return []slog.Attr{slog.Int("rules", len(policy.Allow))}
+			},
+		},
+	}
+	if err := loader.file.load(); err != nil {
+		return nil, err
+	}
+	return loader, nil
+}
+
+// StartLoop reloads the policy whenever the file changes until the context is cancelled.
+func (l *PeerPolicyLoader) StartLoop(ctx context.Context) error {
+	return l.file.StartLoop(ctx)
+}
+
+// Watch returns a channel of reload events, which is closed when the context is cancelled.
+func (l *PeerPolicyLoader) Watch(ctx context.Context) <-chan PeerPolicyReloadEvent {
+	return l.file.Watch(ctx)
+}
+
+func (l *PeerPolicyLoader) Policy() *PeerPolicy {
+	return l.file.Load()
+}
+
+// VerifyPeer is a PeerVerifier that authorizes the peer certificate against the current
+// policy, and logs the reasons of denials.
+func (l *PeerPolicyLoader) VerifyPeer(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("%w: no peer certificate", ErrPeerNotAllowed)
+	}
+	cert := state.PeerCertificates[0]
+	if err := l.Policy().Authorize(cert); err != nil {
+		l.options.Logger.Warn("Denied peer by policy",
+			slog.String("subject", cert.Subject.String()),
+			slog.String("serial", formatSerial(cert.SerialNumber)),
+			slog.String("server-name", state.ServerName),
+			slog.String("reason", err.Error()),
+		)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package mtls
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerPolicyLoader(t *testing.T) {
+	t.Parallel()
+
+	const ServerName = "test-server"
+
+	var (
+		ca            = fakeCA(fakeCATemplate())
+		serverKeyPair = ca.Sign(fakeServerTemplate(func(template *x509.Certificate) {
+			template.DNSNames = []string{ServerName}
+			template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		}))
+		clientKeyPair = ca.Sign(fakeClientTemplate())
+		policyFile    = filepath.Join(t.TempDir(), "policy.json")
+	)
+	writePolicy := func(policy string) {
+		tmp := policyFile + ".tmp"
+		require.NoError(t, os.WriteFile(tmp, []byte(policy), 0o600))
+		require.NoError(t, os.Rename(tmp, policyFile))
+	}
+	writePolicy(`{"allow": [{"name": "test-client", "commonNames": ["test-client"]}]}`)
+
+	loader, err := NewPeerPolicyLoader(PeerPolicyLoaderOptions{
+		Path:           policyFile,
+		ReloadInterval: 50 * time.Millisecond,
+		WatchDebounce:  10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = loader.StartLoop(ctx) }()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = CreateTLSConfigForServer(&fakeKeyPairLoader{keyPair: serverKeyPair}, loader.VerifyPeer)
+	server.StartTLS()
+	defer server.Close()
+
+	get := func() error {
+		client := http.Client{Transport: CreateDynamicTLSTransport(&fakeKeyPairLoader{keyPair: clientKeyPair})}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	t.Log("an allowed client is accepted")
+	require.NoError(t, get())
+
+	t.Log("the client is denied once the policy is changed")
+	writePolicy(`{"allow": [{"name": "ops", "commonNames": ["ops"]}]}`)
+	assert.Eventually(t, func() bool { return get() != nil }, 2*time.Second, 20*time.Millisecond)
+
+	t.Log("an invalid policy is ignored and the previous one stays in use")
+	writePolicy(`{"allow": [{"name": "broken"}]}`)
+	time.Sleep(200 * time.Millisecond)
+	require.Len(t, loader.Policy().Allow, 1)
+	assert.Equal(t, "ops", loader.Policy().Allow[0].Name)
+	assert.Error(t, get())
+
+	t.Log("the client is accepted again once it is allowed")
+	writePolicy(`{"allow": [{"name": "ops", "commonNames": ["ops"]}, {"commonNames": ["test-client"]}]}`)
+	assert.Eventually(t, func() bool { return get() == nil }, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestPeerPolicyLoader_StartLoop(t *testing.T) {
+	t.Parallel()
+
+	var (
+		policyFile  = filepath.Join(t.TempDir(), "policy.json")
+		writePolicy = func(policy string) {
+			tmp := policyFile + ".tmp"
+			require.NoError(t, os.WriteFile(tmp, []byte(policy), 0o600))
+			require.NoError(t, os.Rename(tmp, policyFile))
+		}
+		onErrorCalls atomic.Int32
+	)
+	writePolicy(`{"allow": [{"name": "test-client", "commonNames": ["test-client"]}]}`)
+
+	loader, err := NewPeerPolicyLoader(PeerPolicyLoaderOptions{
+		Path:                   policyFile,
+		ReloadInterval:         50 * time.Millisecond,
+		WatchDebounce:          10 * time.Millisecond,
+		OnError:                func(error) { onErrorCalls.Add(1) },
+		MaxConsecutiveFailures: 3,
+		RetryBackoff:           10 * time.Millisecond,
+		MaxRetryBackoff:        20 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	initial := loader.Policy()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := loader.Watch(ctx)
+	errC := make(chan error, 1)
+	go func() { errC <- loader.StartLoop(ctx) }()
+
+	t.Log("a new policy is reported to the subscribers")
+	writePolicy(`{"allow": [{"name": "ops", "commonNames": ["ops"]}]}`)
+	event := <-events
+	require.True(t, event.Succeeded())
+	assert.Equal(t, uint64(2), event.Generation)
+	assert.Same(t, initial, event.Previous)
+	assert.Same(t, loader.Policy(), event.Current)
+
+	t.Log("an invalid policy is retried with backoff until the loop gives up")
+	writePolicy(`{"allow": [{"name": "broken"}]}`)
+	event = <-events
+	require.ErrorIs(t, event.Err, ErrParsePeerPolicy)
+	assert.Equal(t, uint64(2), event.Generation)
+	assert.Same(t, event.Previous, event.Current)
+
+	select {
+	case err := <-errC:
+		require.ErrorIs(t, err, ErrTooManyReloadFailures)
+		require.ErrorIs(t, err, ErrParsePeerPolicy)
+	case <-time.After(2 * time.Second):
+		t.Fatal("StartLoop did not give up")
+	}
+	assert.Equal(t, int32(3), onErrorCalls.Load())
+	assert.Equal(t, "ops", loader.Policy().Allow[0].Name)
+}
+
+func TestNewPeerPolicyLoader(t *testing.T) {
+	t.Parallel()
+
+	t.Run("it should fail if the policy file does not exist", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewPeerPolicyLoader(PeerPolicyLoaderOptions{Path: filepath.Join(t.TempDir(), "missing.json")})
+		require.Error(t, err)
+	})
+
+	t.Run("it should fail if the policy is invalid", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "policy.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"allow": [{}]}`), 0o600))
+		_, err := NewPeerPolicyLoader(PeerPolicyLoaderOptions{Path: path})
+		require.ErrorIs(t, err, ErrParsePeerPolicy)
+	})
+}
@@ -0,0 +1,96 @@
+package mtls
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePeerPolicy(t *testing.T) {
+	t.Parallel()
+
+	t.Run("it should parse rules and name unnamed ones", func(t *testing.T) {
+		t.Parallel()
+		policy, err := ParsePeerPolicy([]byte(`{"allow": [
+			{"name": "frontend", "uris": ["spiffe://example.org/frontend"]},
+			{"commonNames": ["ops"]}
+		]}`))
+		require.NoError(t, err)
+		require.Len(t, policy.Allow, 2)
+		assert.Equal(t, "frontend", policy.Allow[0].Name)
+		assert.Equal(t, "#2", policy.Allow[1].Name)
+	})
+
+	t.Run("it should reject malformed policies", func(t *testing.T) {
+		t.Parallel()
+		for _, data := range []string{
+			`not json`,
+			`{"allow": [{"commonName": ["typo"]}]}`,
+			`{"allow": [{"name": "empty"}]}`,
+			`{"allow": [{"spkiSHA256": ["not-a-fingerprint"]}]}`,
+		} {
+			_, err := ParsePeerPolicy([]byte(data))
+			assert.ErrorIs(t, err, ErrParsePeerPolicy, data)
+		}
+	})
+}
+
+func TestPeerPolicy_Authorize(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca   = fakeCA(fakeCATemplate())
+		cert = ca.Sign(fakeClientTemplate(func(template *x509.Certificate) {
+			template.Subject = pkix.Name{CommonName: "billing", OrganizationalUnit: []string{"payments"}}
+			template.DNSNames = []string{"billing.payments.svc"}
+		}, withSPIFFEID("spiffe://example.org/ns/payments/sa/billing"))).Certificate.Leaf
+		fingerprint = sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	)
+
+	authorize := func(rule string) error {
+		policy, err := ParsePeerPolicy([]byte(`{"allow": [` + rule + `]}`))
+		require.NoError(t, err)
+		return policy.Authorize(cert)
+	}
+
+	t.Run("it should allow certificates matching a rule", func(t *testing.T) {
+		t.Parallel()
+		for _, rule := range []string{
+			`{"commonNames": ["other", "billing"]}`,
+			`{"dnsNames": ["billing.payments.svc"]}`,
+			`{"uris": ["spiffe://example.org/ns/payments/sa/billing"]}`,
+			`{"organizationalUnits": ["payments"]}`,
+			fmt.Sprintf(`{"spkiSHA256": [%q]}`, hex.EncodeToString(fingerprint[:])),
+			fmt.Sprintf(`{"spkiSHA256": [%q]}`, base64.StdEncoding.EncodeToString(fingerprint[:])),
+			`{"commonNames": ["billing"], "organizationalUnits": ["payments"]}`,
+			`{"commonNames": ["other"]}, {"commonNames": ["billing"]}`,
+		} {
+			assert.NoError(t, authorize(rule), rule)
+		}
+	})
+
+	t.Run("it should deny certificates matching no rule with reasons", func(t *testing.T) {
+		t.Parallel()
+		err := authorize(`{"name": "ops", "commonNames": ["ops"]}, {"name": "web", "commonNames": ["billing"], "organizationalUnits": ["web"]}`)
+		require.ErrorIs(t, err, ErrPeerNotAllowed)
+		assert.ErrorContains(t, err, `rule ops: common name "billing" is not one of ["ops"]`)
+		assert.ErrorContains(t, err, `rule web: organizational units ["payments"] are not any of ["web"]`)
+
+		err = authorize(`{"spkiSHA256": ["` + hex.EncodeToString(make([]byte, sha256.Size)) + `"]}`)
+		assert.ErrorContains(t, err, "SPKI fingerprint "+hex.EncodeToString(fingerprint[:])+" is not allowed")
+	})
+
+	t.Run("it should deny everyone with an empty policy", func(t *testing.T) {
+		t.Parallel()
+		policy, err := ParsePeerPolicy([]byte(`{"allow": []}`))
+		require.NoError(t, err)
+		assert.ErrorIs(t, policy.Authorize(cert), ErrPeerNotAllowed)
+	})
+}
@@ -0,0 +1,34 @@
+package mtls
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrVerifyPeer = errors.New("verify peer")
+
+// PeerVerifier checks the peer of a TLS connection after its certificate chain has been
+// verified against the CA bundle. Returning an error aborts the handshake.
+//
+// On the server side it is called with the client certificate, on the client side with
+// the server certificate. state.VerifiedChains holds the verified chains.
+type PeerVerifier func(state tls.ConnectionState) error
+
+// verifyConnection combines the verifiers into a tls.Config.VerifyConnection callback.
+// Nil verifiers are skipped, and it returns nil if there are no verifiers left.
+func verifyConnection(verifiers []PeerVerifier) func(state tls.ConnectionState) error {
+	verifiers = slices.DeleteFunc(slices.Clone(verifiers), func(v PeerVerifier) bool { return v == nil })
+	if len(verifiers) == 0 {
+		return nil
+	}
+	return func(state tls.ConnectionState) error {
+		for _, verify := range verifiers {
+			if err := verify(state); err != nil {
+				return fmt.Errorf("%w: %w", ErrVerifyPeer, err)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,42 @@
+package mtls
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+var ErrDecodePKCS12 = errors.New("decode PKCS#12 keystore")
+
+// decodePKCS12 extracts the CA bundle, certificate chain and key PEMs that make up a
+// TLSKeyPairRaw from a PKCS#12 keystore.
+//
+// The leaf is followed by the intermediates of the keystore. Self-signed CA certificates
+// in the keystore are returned as the CA bundle instead of being sent to peers.
+func decodePKCS12(data []byte, password string) (caPEM, certPEM, keyPEM []byte, err error) {
+	key, leaf, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %w", ErrDecodePKCS12, err)
+	}
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: marshal private key: %w", ErrDecodePKCS12, err)
+	}
+
+	chain := []*pem.Block{{Type: "CERTIFICATE", Bytes: leaf.Raw}}
+	var roots []*pem.Block
+	for _, cert := range caCerts {
+		block := &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}
+		if cert.IsCA && isSelfSigned(cert) {
+			roots = append(roots, block)
+		} else {
+			chain = append(chain, block)
+		}
+	}
+
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes})
+	return encodePEMBlocks(roots), encodePEMBlocks(chain), keyPEM, nil
+}
@@ -0,0 +1,148 @@
+package mtls
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func mustEncodePKCS12(keyPair *TLSKeyPair, caCerts []*x509.Certificate, password string) []byte {
+	data, err := pkcs12.Modern.Encode(keyPair.Certificate.PrivateKey, keyPair.Certificate.Leaf, caCerts, password)
+	PanicIfErr(err)
+	return data
+}
+
+func TestDecodePKCS12(t *testing.T) {
+	t.Parallel()
+
+	const Password = "s3cret"
+
+	var (
+		root         = fakeCA(fakeCATemplate())
+		intermediate = root.SignIntermediate(fakeCATemplate(func(template *x509.Certificate) {
+			template.Subject.CommonName = "test-intermediate"
+		}))
+		keyPair  = intermediate.Sign(fakeServerTemplate())
+		keystore = mustEncodePKCS12(keyPair, []*x509.Certificate{intermediate.Certificate, root.Certificate}, Password)
+	)
+
+	t.Run("it should split the keystore into key, chain and trust anchors", func(t *testing.T) {
+		t.Parallel()
+
+		caPEM, certPEM, keyPEM, err := decodePKCS12(keystore, Password)
+		require.NoError(t, err)
+		assert.Equal(t, ToCertificatePEM(root.Certificate.Raw), caPEM)
+		assert.Equal(t, bytes.Join([][]byte{
+			ToCertificatePEM(keyPair.Certificate.Leaf.Raw),
+			ToCertificatePEM(intermediate.Certificate.Raw),
+		}, nil), certPEM)
+		assert.Equal(t, ToPrivateKeyPEM(keyPair.Certificate.PrivateKey), keyPEM)
+	})
+
+	t.Run("it should return an error for a wrong password", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, _, err := decodePKCS12(keystore, "wrong")
+		require.ErrorIs(t, err, ErrDecodePKCS12)
+	})
+}
+
+func TestNewLocalFileTLSConfigLoader_PKCS12(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca      = fakeCA(fakeCATemplate())
+		keyPair = ca.Sign(fakeServerTemplate())
+	)
+
+	t.Run("it should load the key pair and trust anchors from the keystore", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		keystore := filepath.Join(dir, "tls.p12")
+		passwordFile := filepath.Join(dir, "password")
+		require.NoError(t, os.WriteFile(keystore, mustEncodePKCS12(keyPair, []*x509.Certificate{ca.Certificate}, "first"), 0600))
+		require.NoError(t, os.WriteFile(passwordFile, []byte("first\n"), 0600))
+
+		loader, err := NewLocalFileTLSConfigLoader(LocalFileTLSConfigLoaderOptions{
+			PKCS12:         keystore,
+			KeyPassphrase:  PassphraseFromFile(passwordFile),
+			ReloadInterval: 1 * time.Hour,
+			Validate:       ValidateKeyPairForServerUsage,
+		})
+		require.NoError(t, err)
+		assert.True(t, loader.KeyPair().Equal(keyPair))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		events := loader.Watch(ctx)
+		errC := make(chan error, 1)
+		go func() {
+			errC <- loader.StartLoop(ctx)
+		}()
+
+		// Rotate the key pair together with the password.
+		newKeyPair := ca.Sign(fakeServerTemplate())
+		require.NoError(t, os.WriteFile(passwordFile, []byte("second\n"), 0600))
+		require.NoError(t, os.WriteFile(keystore, mustEncodePKCS12(newKeyPair, []*x509.Certificate{ca.Certificate}, "second"), 0600))
+
+		require.Eventually(t, func() bool {
+			return loader.KeyPair().Equal(newKeyPair)
+		}, 5*time.Second, 10*time.Millisecond)
+		for len(events) > 0 {
+			<-events // Drain failures from reading the keystore halfway through the rotation.
+		}
+
+		cancel()
+		require.NoError(t, <-errC)
+	})
+
+	t.Run("it should read the password from the environment and the CA bundle from a file", func(t *testing.T) {
+		t.Parallel()
+
+		const PasswordEnv = "MTLS_TEST_PKCS12_PASSWORD"
+		require.NoError(t, os.Setenv(PasswordEnv, "from-env"))
+		defer os.Unsetenv(PasswordEnv)
+
+		dir := t.TempDir()
+		keystore := filepath.Join(dir, "tls.p12")
+		bundle := filepath.Join(dir, "ca.pem")
+		require.NoError(t, os.WriteFile(keystore, mustEncodePKCS12(keyPair, nil, "from-env"), 0600))
+		require.NoError(t, os.WriteFile(bundle, ToCertificatePEM(ca.Certificate.Raw), 0600))
+
+		loader, err := NewLocalFileTLSConfigLoader(LocalFileTLSConfigLoaderOptions{
+			CABundle:      bundle,
+			PKCS12:        keystore,
+			KeyPassphrase: PassphraseFromEnv(PasswordEnv),
+			Validate:      ValidateKeyPairForServerUsage,
+		})
+		require.NoError(t, err)
+		assert.True(t, loader.KeyPair().Equal(keyPair))
+	})
+
+	t.Run("it should return an error if the password is wrong", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		keystore := filepath.Join(dir, "tls.p12")
+		passwordFile := filepath.Join(dir, "password")
+		require.NoError(t, os.WriteFile(keystore, mustEncodePKCS12(keyPair, []*x509.Certificate{ca.Certificate}, "right"), 0600))
+		require.NoError(t, os.WriteFile(passwordFile, []byte("wrong"), 0600))
+
+		_, err := NewLocalFileTLSConfigLoader(LocalFileTLSConfigLoaderOptions{
+			PKCS12:        keystore,
+			KeyPassphrase: PassphraseFromFile(passwordFile),
+			Validate:      ValidateKeyPairForServerUsage,
+		})
+		require.ErrorIs(t, err, ErrDecodePKCS12)
+	})
+}
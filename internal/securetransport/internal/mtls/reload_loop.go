@@ -0,0 +1,119 @@
+package mtls
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// reloadLoop runs the StartLoop of the loaders. It reloads something, e.g. a key pair or a
+// peer policy, whenever it may have changed. Failed reloads are logged, passed to onError
+// and retried with exponential backoff while the current value stays in use; the loop
+// gives up with ErrTooManyReloadFailures after maxConsecutiveFailures in a row.
+type reloadLoop struct {
+	name                   string      // What is reloaded, for the logs, e.g. "peer policy"
+	attrs                  []slog.Attr // Attributes of the failure logs, e.g. the path
+	interval               time.Duration
+	logger                 *slog.Logger
+	watch                  func(ctx context.Context, notify func()) // Calls notify on changes until the context is cancelled; optional
+	changed                func() bool                              // Reports whether a poll should reload
+	reload                 func() error
+	onError                func(error)
+	maxConsecutiveFailures int // 0 retries forever
+	retryBackoff           time.Duration
+	maxRetryBackoff        time.Duration
+}
+
+// run calls reload until the context is cancelled, as soon as watch notifies a change, and
+// every interval unless changed reports no changes. It returns an error wrapping
+// ErrTooManyReloadFailures when it gives up.
+func (l *reloadLoop) run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	changed := make(chan struct{}, 1)
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		if l.watch == nil {
+			return
+		}
+		l.watch(ctx, func() {
+			select {
+			case changed <- struct{}{}:
+			default: // A reload is already pending.
+			}
+		})
+	}()
+	defer func() {
+		cancel()
+		<-watchDone
+	}()
+
+	var failures int
+	timer := time.NewTimer(l.interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		case <-timer.C:
+			if failures == 0 && !l.changed() {
+				timer.Reset(l.interval)
+				continue
+			}
+		}
+
+		err := l.reload()
+		if err == nil {
+			failures = 0
+			timer.Reset(l.interval)
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		failures++
+		if l.onError != nil {
+			l.onError(err)
+		}
+		if l.maxConsecutiveFailures > 0 && failures >= l.maxConsecutiveFailures {
+			l.logger.LogAttrs(ctx, slog.LevelError, "Giving up reloading "+l.name, append(l.attrs,
+				slog.String("error", err.Error()),
+				slog.Int("consecutive-failures", failures),
+			)...)
+			return fmt.Errorf("%w (%d): %w", ErrTooManyReloadFailures, failures, err)
+		}
+		delay := retryBackoff(failures, l.retryBackoff, l.maxRetryBackoff)
+		l.logger.LogAttrs(ctx, slog.LevelError, "Failed to reload "+l.name, append(l.attrs,
+			slog.String("error", err.Error()),
+			slog.Int("consecutive-failures", failures),
+			slog.Duration("retry-in", delay),
+		)...)
+		timer.Reset(delay)
+	}
+}
+
+// watchFiles returns a watch function of reloadLoop that notifies debounced changes of the
+// files, from filesystem events on their parent directories.
+func watchFiles(paths []string, debounce, retry time.Duration, logger *slog.Logger) func(ctx context.Context, notify func()) {
+	return func(ctx context.Context, notify func()) {
+		watcher := newFileWatcher(paths, debounce, retry, logger)
+		watcherDone := make(chan struct{})
+		go func() {
+			defer close(watcherDone)
+			watcher.Run(ctx)
+		}()
+		defer func() { <-watcherDone }()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-watcher.C():
+				notify()
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package mtls
+
+import (
+	"context"
+	"sync"
+)
+
+// ReloadEventBufferSize is the number of events buffered per subscriber.
+// Events are dropped for subscribers that fall further behind; gaps can be
+// detected through ReloadEvent.Generation.
+const ReloadEventBufferSize = 16
+
+// ReloadEvent describes the outcome of a reload attempt that found new content.
+// Attempts that find the same key pair as the one in use are not reported.
+type ReloadEvent struct {
+	Generation uint64      // Generation of the key pair in use after the attempt; starts at 1
+	Previous   *TLSKeyPair // Previous is the key pair in use before the attempt
+	Current    *TLSKeyPair // Current is the key pair in use after the attempt; same as Previous on failure
+	Rejected   *TLSKeyPair // Rejected is the parsed key pair that failed validation, if any
+	Err        error       // Err is the reason the attempt failed; nil on success
+}
+
+// Succeeded reports whether the new key pair was swapped in.
+func (e ReloadEvent) Succeeded() bool {
+	return e.Err == nil
+}
+
+// reloadNotifier fans out the events of a loader, e.g. ReloadEvent or PeerPolicyReloadEvent,
+// to its Watch subscribers.
+type reloadNotifier[E any] struct {
+	mu          sync.Mutex
+	subscribers map[chan E]struct{}
+}
+
+// Watch subscribes to reload events until the context is cancelled,
+// after which the returned channel is closed.
+func (n *reloadNotifier[E]) Watch(ctx context.Context) <-chan E {
+	ch := make(chan E, ReloadEventBufferSize)
+
+	n.mu.Lock()
+	if n.subscribers == nil {
+		n.subscribers = make(map[chan E]struct{})
+	}
+	n.subscribers[ch] = struct{}{}
+	n.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		delete(n.subscribers, ch)
+		close(ch)
+	}()
+
+	return ch
+}
+
+func (n *reloadNotifier[E]) notify(event E) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for ch := range n.subscribers {
+		select {
+		case ch <- event:
+		default: // Never block the reload loop on a slow subscriber.
+		}
+	}
+}
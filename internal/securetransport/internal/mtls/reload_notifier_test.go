@@ -0,0 +1,63 @@
+package mtls
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadNotifier(t *testing.T) {
+	t.Parallel()
+
+	t.Run("it should deliver events to every subscriber", func(t *testing.T) {
+		t.Parallel()
+
+		var n reloadNotifier[ReloadEvent]
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		first, second := n.Watch(ctx), n.Watch(ctx)
+		n.notify(ReloadEvent{Generation: 1})
+
+		assert.Equal(t, uint64(1), (<-first).Generation)
+		assert.Equal(t, uint64(1), (<-second).Generation)
+	})
+
+	t.Run("it should close the channel when the context is cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		var n reloadNotifier[ReloadEvent]
+		ctx, cancel := context.WithCancel(context.Background())
+		events := n.Watch(ctx)
+		cancel()
+
+		_, ok := <-events
+		assert.False(t, ok)
+		n.notify(ReloadEvent{Generation: 1}) // must not panic on the closed channel
+	})
+
+	t.Run("it should drop events for slow subscribers instead of blocking", func(t *testing.T) {
+		t.Parallel()
+
+		var n reloadNotifier[ReloadEvent]
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		events := n.Watch(ctx)
+		for i := range ReloadEventBufferSize + 5 {
+			n.notify(ReloadEvent{Generation: uint64(i + 1)})
+		}
+		require.Len(t, events, ReloadEventBufferSize)
+		assert.Equal(t, uint64(1), (<-events).Generation)
+	})
+}
+
+func TestReloadEvent_Succeeded(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, ReloadEvent{}.Succeeded())
+	assert.False(t, ReloadEvent{Err: errors.New("boom")}.Succeeded())
+}
@@ -0,0 +1,235 @@
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/credentials"
+)
+
+// KeyPairLoader keeps a key pair up to date, e.g. a SourceServerTLSConfigLoader from
+// NewLocalFileServerTLSConfigLoader, with its own options and validation.
+type KeyPairLoader interface {
+	StartLoop(ctx context.Context) error
+	KeyPair() *TLSKeyPair
+	Watch(ctx context.Context) <-chan ReloadEvent
+}
+
+// ServerIdentity is a key pair served to the clients asking for one of its server names,
+// and how those clients are verified.
+//
+// The server loaders of this package keep verifying clients as configured when used as the
+// loader of an identity: their PeerVerifiers check the clients of the identity, on top of
+// the PeerVerifiers of the SNIServerTLSConfigLoader, and their ClientAuth applies unless
+// the identity sets one. An identity and its loader setting different modes is an error,
+// so that neither loosens the client auth of the other.
+type ServerIdentity struct {
+	ServerNames []string        // Exact names, or wildcards like *.example.com matching a single label
+	Loader      KeyPairLoader   // Loader of the key pair of the identity
+	ClientCAs   *CABundleLoader // Verifies the clients of the identity; defaults to the CA bundle of the key pair
+	ClientAuth  ClientAuthMode  // Whether the clients of the identity must present a certificate; defaults to the ClientAuth of the loader, or RequireClientCert
+}
+
+// clientVerifier is implemented by the server loaders of this package, so that
+// SNIServerTLSConfigLoader verifies the clients of their identities like they do.
+type clientVerifier interface {
+	clientVerification() (ClientAuthMode, []PeerVerifier)
+}
+
+// sniIdentity is a ServerIdentity with the client verification of its loader resolved.
+type sniIdentity struct {
+	ServerIdentity
+	clientAuth ClientAuthMode
+	verify     func(state tls.ConnectionState) error
+}
+
+func newSNIIdentity(identity ServerIdentity, verifiers []PeerVerifier) (*sniIdentity, error) {
+	clientAuth := identity.ClientAuth
+	if loader, ok := identity.Loader.(clientVerifier); ok {
+		loaderClientAuth, loaderVerifiers := loader.clientVerification()
+		switch {
+		case clientAuth == ClientAuthDefault:
+			clientAuth = loaderClientAuth
+		case loaderClientAuth != ClientAuthDefault && loaderClientAuth != clientAuth:
+			return nil, fmt.Errorf("client auth of the identity conflicts with the client auth of its loader")
+		}
+		verifiers = append(slices.Clone(verifiers), loaderVerifiers...)
+	}
+	return &sniIdentity{ServerIdentity: identity, clientAuth: clientAuth, verify: verifyClient(verifiers)}, nil
+}
+
+func (i *sniIdentity) handshake() serverHandshake {
+	keyPair := i.Loader.KeyPair()
+	clientCAs := keyPair.CAs
+	if i.ClientCAs != nil {
+		clientCAs = i.ClientCAs.CAPool()
+	}
+	return serverHandshake{keyPair: keyPair, clientCAs: clientCAs, clientAuth: i.clientAuth, verify: i.verify}
+}
+
+type SNIServerTLSConfigLoaderOptions struct {
+	Identities    []ServerIdentity // Identities selected by the SNI of the client hello
+	Default       ServerIdentity   // Served when the client sends no SNI, or no identity matches it; ServerNames are ignored
+	PeerVerifiers []PeerVerifier   // Verify the clients of every identity
+}
+
+// SNIServerTLSConfigLoader serves several identities behind one listener, picking the key
+// pair and the client verification by the server name the client asks for. Each identity is
+// reloaded and validated by its own loader, and so is its client CA bundle, if any.
+//
+// Exact server names take precedence over wildcards, and longer wildcards over shorter ones.
+type SNIServerTLSConfigLoader struct {
+	exact     map[string]*sniIdentity
+	wildcards []sniWildcard // sorted from the longest suffix
+	fallback  *sniIdentity
+	loops     []looper
+	loaders   []KeyPairLoader
+}
+
+type looper interface {
+	StartLoop(ctx context.Context) error
+}
+
+type sniWildcard struct {
+	suffix   string // suffix is the pattern without the leading "*", e.g. ".example.com"
+	identity *sniIdentity
+}
+
+func NewSNIServerTLSConfigLoader(options SNIServerTLSConfigLoaderOptions) (*SNIServerTLSConfigLoader, error) {
+	if options.Default.Loader == nil {
+		return nil, fmt.Errorf("loader of the default identity is nil")
+	}
+	fallback, err := newSNIIdentity(options.Default, options.PeerVerifiers)
+	if err != nil {
+		return nil, fmt.Errorf("default identity: %w", err)
+	}
+	rv := &SNIServerTLSConfigLoader{
+		exact:    map[string]*sniIdentity{},
+		fallback: fallback,
+	}
+	rv.track(rv.fallback)
+	for i, serverIdentity := range options.Identities {
+		if serverIdentity.Loader == nil {
+			return nil, fmt.Errorf("loader of identity #%d is nil", i)
+		}
+		if len(serverIdentity.ServerNames) == 0 {
+			return nil, fmt.Errorf("identity #%d has no server names", i)
+		}
+		identity, err := newSNIIdentity(serverIdentity, options.PeerVerifiers)
+		if err != nil {
+			return nil, fmt.Errorf("identity #%d: %w", i, err)
+		}
+		for _, name := range identity.ServerNames {
+			if err := rv.add(strings.ToLower(name), identity); err != nil {
+				return nil, fmt.Errorf("identity #%d: %w", i, err)
+			}
+		}
+		rv.track(identity)
+	}
+	slices.SortStableFunc(rv.wildcards, func(a, b sniWildcard) int {
+		return len(b.suffix) - len(a.suffix)
+	})
+	return rv, nil
+}
+
+// track records the loaders of the identity to run and watch, once each.
+func (l *SNIServerTLSConfigLoader) track(identity *sniIdentity) {
+	if !slices.Contains(l.loaders, identity.Loader) {
+		l.loaders = append(l.loaders, identity.Loader)
+		l.loops = append(l.loops, identity.Loader)
+	}
+	if identity.ClientCAs != nil && !slices.Contains(l.loops, looper(identity.ClientCAs)) {
+		l.loops = append(l.loops, identity.ClientCAs)
+	}
+}
+
+func (l *SNIServerTLSConfigLoader) add(pattern string, identity *sniIdentity) error {
+	suffix, wildcard := strings.CutPrefix(pattern, "*")
+	switch {
+	case pattern == "" || strings.Contains(suffix, "*"):
+		return fmt.Errorf("invalid server name %q", pattern)
+	case wildcard && (!strings.HasPrefix(suffix, ".") || len(suffix) < 2):
+		return fmt.Errorf("invalid wildcard %q, expected *.<domain>", pattern)
+	}
+	if wildcard {
+		for _, w := range l.wildcards {
+			if w.suffix == suffix {
+				return fmt.Errorf("duplicate server name %q", pattern)
+			}
+		}
+		l.wildcards = append(l.wildcards, sniWildcard{suffix: suffix, identity: identity})
+		return nil
+	}
+	if _, ok := l.exact[pattern]; ok {
+		return fmt.Errorf("duplicate server name %q", pattern)
+	}
+	l.exact[pattern] = identity
+	return nil
+}
+
+// identityFor returns the identity serving the server name.
+func (l *SNIServerTLSConfigLoader) identityFor(serverName string) *sniIdentity {
+	serverName = strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if identity, ok := l.exact[serverName]; ok {
+		return identity
+	}
+	for _, w := range l.wildcards {
+		label, ok := strings.CutSuffix(serverName, w.suffix)
+		if ok && label != "" && !strings.Contains(label, ".") {
+			return w.identity
+		}
+	}
+	return l.fallback
+}
+
+// StartLoop runs the loops of all the identities and client CA bundles until the context
+// is cancelled, or one of them fails.
+func (l *SNIServerTLSConfigLoader) StartLoop(ctx context.Context) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	for _, loop := range l.loops {
+		eg.Go(func() error {
+			return loop.StartLoop(ctx)
+		})
+	}
+	return eg.Wait()
+}
+
+// Watch merges the reload events of all the identities. Generations are counted per
+// identity; ReloadEvent.Current tells which identity an event is about.
+func (l *SNIServerTLSConfigLoader) Watch(ctx context.Context) <-chan ReloadEvent {
+	rv := make(chan ReloadEvent, ReloadEventBufferSize)
+	var wg sync.WaitGroup
+	for _, loader := range l.loaders {
+		events := loader.Watch(ctx)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for event := range events {
+				select {
+				case rv <- event:
+				default: // The subscriber fell behind, like reloadNotifier.
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(rv)
+	}()
+	return rv
+}
+
+func (l *SNIServerTLSConfigLoader) ServerTLSConfig() *tls.Config {
+	return createTLSConfigForServer(func(info *tls.ClientHelloInfo) serverHandshake {
+		return l.identityFor(info.ServerName).handshake()
+	})
+}
+
+func (l *SNIServerTLSConfigLoader) GRPCServerCredentials() credentials.TransportCredentials {
+	return credentials.NewTLS(l.ServerTLSConfig())
+}
@@ -0,0 +1,211 @@
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSNIServerTLSConfigLoader(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca     = fakeCA(fakeCATemplate())
+		static = func(commonName string) *StaticServerTLSConfigLoader {
+			loader, err := NewStaticServerTLSConfigLoader(ca.Sign(fakeServerTemplate(func(template *x509.Certificate) {
+				template.Subject.CommonName = commonName
+			})))
+			PanicIfErr(err)
+			return loader
+		}
+		fallback = static("default")
+		api      = static("api")
+		tenants  = static("tenants")
+	)
+
+	loader, err := NewSNIServerTLSConfigLoader(SNIServerTLSConfigLoaderOptions{
+		Identities: []ServerIdentity{
+			{ServerNames: []string{"api.internal", "API.example.com"}, Loader: api},
+			{ServerNames: []string{"*.tenants.internal", "*.internal"}, Loader: tenants},
+		},
+		Default: ServerIdentity{Loader: fallback},
+	})
+	require.NoError(t, err)
+
+	t.Run("it should serve the identity matching the server name", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			ServerName string
+			Expected   string
+		}{
+			{ServerName: "api.internal", Expected: "api"},
+			{ServerName: "api.example.com", Expected: "api"},
+			{ServerName: "API.internal.", Expected: "api"},
+			{ServerName: "a.tenants.internal", Expected: "tenants"},
+			{ServerName: "other.internal", Expected: "tenants"},
+			{ServerName: "a.b.tenants.internal", Expected: "default"},
+			{ServerName: "internal", Expected: "default"},
+			{ServerName: "", Expected: "default"},
+		}
+		config := loader.ServerTLSConfig()
+		for _, tt := range tests {
+			serverConfig, err := config.GetConfigForClient(&tls.ClientHelloInfo{ServerName: tt.ServerName})
+			require.NoError(t, err)
+			cert, err := serverConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: tt.ServerName})
+			require.NoError(t, err)
+			assert.Equal(t, tt.Expected, cert.Leaf.Subject.CommonName, "server name %q", tt.ServerName)
+		}
+	})
+
+	t.Run("it should report the reloads of every identity", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		events := loader.Watch(ctx)
+
+		rotated := ca.Sign(fakeServerTemplate(func(template *x509.Certificate) {
+			template.Subject.CommonName = "api"
+		}))
+		require.NoError(t, api.Set(rotated))
+		event := <-events
+		assert.True(t, event.Succeeded())
+		assert.Same(t, rotated, event.Current)
+
+		cancel()
+		for range events {
+		}
+	})
+
+	t.Run("it should verify the clients of each identity with its own trust bundle", func(t *testing.T) {
+		t.Parallel()
+
+		tenantCA := fakeCA(fakeCATemplate())
+		bundleFile := filepath.Join(t.TempDir(), "tenant-ca.pem")
+		require.NoError(t, os.WriteFile(bundleFile, ToCertificatePEM(tenantCA.Certificate.Raw), 0o600))
+		clientCAs, err := NewCABundleLoader(CABundleLoaderOptions{FileLoaderOptions: FileLoaderOptions{Path: bundleFile}})
+		require.NoError(t, err)
+
+		loader, err := NewSNIServerTLSConfigLoader(SNIServerTLSConfigLoaderOptions{
+			Identities: []ServerIdentity{
+				{ServerNames: []string{"*.tenants.internal"}, Loader: tenants, ClientCAs: clientCAs, ClientAuth: VerifyClientCertIfGiven},
+				{ServerNames: []string{"public.internal"}, Loader: api, ClientAuth: NoClientCert},
+			},
+			Default: ServerIdentity{Loader: fallback},
+		})
+		require.NoError(t, err)
+
+		tests := []struct {
+			ServerName string
+			ClientCAs  *x509.CertPool
+			ClientAuth tls.ClientAuthType
+		}{
+			{ServerName: "a.tenants.internal", ClientCAs: tenantCA.pool(), ClientAuth: tls.VerifyClientCertIfGiven},
+			{ServerName: "public.internal", ClientCAs: ca.pool(), ClientAuth: tls.NoClientCert},
+			{ServerName: "", ClientCAs: ca.pool(), ClientAuth: tls.RequireAndVerifyClientCert},
+		}
+		config := loader.ServerTLSConfig()
+		for _, tt := range tests {
+			serverConfig, err := config.GetConfigForClient(&tls.ClientHelloInfo{ServerName: tt.ServerName})
+			require.NoError(t, err)
+			assert.Equal(t, tt.ClientAuth, serverConfig.ClientAuth, "server name %q", tt.ServerName)
+			assert.True(t, tt.ClientCAs.Equal(serverConfig.ClientCAs), "server name %q", tt.ServerName)
+		}
+	})
+
+	t.Run("it should verify the clients of each identity with the verifiers of its loader", func(t *testing.T) {
+		t.Parallel()
+
+		errRejected := errors.New("rejected by the loader")
+		hardened, err := NewStaticServerTLSConfigLoader(ca.Sign(fakeServerTemplate()), func(tls.ConnectionState) error {
+			return errRejected
+		})
+		require.NoError(t, err)
+		var global int
+		loader, err := NewSNIServerTLSConfigLoader(SNIServerTLSConfigLoaderOptions{
+			Identities: []ServerIdentity{{ServerNames: []string{"hardened.internal"}, Loader: hardened}},
+			Default:    ServerIdentity{Loader: fallback},
+			PeerVerifiers: []PeerVerifier{func(tls.ConnectionState) error {
+				global++
+				return nil
+			}},
+		})
+		require.NoError(t, err)
+
+		client := ca.Sign(fakeClientTemplate()).Certificate.Leaf
+		state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{client}}
+		config := loader.ServerTLSConfig()
+
+		serverConfig, err := config.GetConfigForClient(&tls.ClientHelloInfo{ServerName: "hardened.internal"})
+		require.NoError(t, err)
+		assert.ErrorIs(t, serverConfig.VerifyConnection(state), errRejected)
+
+		serverConfig, err = config.GetConfigForClient(&tls.ClientHelloInfo{ServerName: "other.example.com"})
+		require.NoError(t, err)
+		assert.NoError(t, serverConfig.VerifyConnection(state))
+		assert.Equal(t, 2, global, "the verifiers of the SNI loader check the clients of every identity")
+	})
+
+	t.Run("it should apply the client auth of the loader of each identity", func(t *testing.T) {
+		t.Parallel()
+
+		source := &fakeKeyPairSource{raw: ca.Sign(fakeServerTemplate()).Raw}
+		optional, err := NewSourceServerTLSConfigLoader(source, SourceTLSConfigLoaderOptions{ClientAuth: VerifyClientCertIfGiven})
+		require.NoError(t, err)
+
+		loader, err := NewSNIServerTLSConfigLoader(SNIServerTLSConfigLoaderOptions{
+			Identities: []ServerIdentity{{ServerNames: []string{"optional.internal"}, Loader: optional}},
+			Default:    ServerIdentity{Loader: fallback},
+		})
+		require.NoError(t, err)
+		serverConfig, err := loader.ServerTLSConfig().GetConfigForClient(&tls.ClientHelloInfo{ServerName: "optional.internal"})
+		require.NoError(t, err)
+		assert.Equal(t, tls.VerifyClientCertIfGiven, serverConfig.ClientAuth)
+
+		_, err = NewSNIServerTLSConfigLoader(SNIServerTLSConfigLoaderOptions{
+			Identities: []ServerIdentity{{ServerNames: []string{"optional.internal"}, Loader: optional, ClientAuth: NoClientCert}},
+			Default:    ServerIdentity{Loader: fallback},
+		})
+		assert.ErrorContains(t, err, "conflicts with the client auth of its loader")
+
+		t.Log("an identity requiring client certificates is not loosened by its loader")
+		_, err = NewSNIServerTLSConfigLoader(SNIServerTLSConfigLoaderOptions{
+			Identities: []ServerIdentity{{ServerNames: []string{"optional.internal"}, Loader: optional, ClientAuth: RequireClientCert}},
+			Default:    ServerIdentity{Loader: fallback},
+		})
+		assert.ErrorContains(t, err, "conflicts with the client auth of its loader")
+
+		strict, err := NewSourceServerTLSConfigLoader(source, SourceTLSConfigLoaderOptions{})
+		require.NoError(t, err)
+		loader, err = NewSNIServerTLSConfigLoader(SNIServerTLSConfigLoaderOptions{
+			Identities: []ServerIdentity{{ServerNames: []string{"strict.internal"}, Loader: strict, ClientAuth: RequireClientCert}},
+			Default:    ServerIdentity{Loader: fallback},
+		})
+		require.NoError(t, err)
+		serverConfig, err = loader.ServerTLSConfig().GetConfigForClient(&tls.ClientHelloInfo{ServerName: "strict.internal"})
+		require.NoError(t, err)
+		assert.Equal(t, tls.RequireAndVerifyClientCert, serverConfig.ClientAuth)
+	})
+
+	t.Run("it should reject invalid server names", func(t *testing.T) {
+		t.Parallel()
+
+		for _, names := range [][]string{{""}, {"*"}, {"*example.com"}, {"a.*.example.com"}, {"api.internal", "API.internal"}} {
+			_, err := NewSNIServerTLSConfigLoader(SNIServerTLSConfigLoaderOptions{
+				Identities: []ServerIdentity{{ServerNames: names, Loader: api}},
+				Default:    ServerIdentity{Loader: fallback},
+			})
+			assert.Error(t, err, "server names %q", names)
+		}
+
+		_, err := NewSNIServerTLSConfigLoader(SNIServerTLSConfigLoaderOptions{})
+		assert.ErrorContains(t, err, "default identity is nil")
+	})
+}
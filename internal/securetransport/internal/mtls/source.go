@@ -0,0 +1,155 @@
+package mtls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+var DefaultReloadInterval = 10 * time.Second
+
+var (
+	ErrValidateKeyPair       = errors.New("validate key pair")
+	ErrTooManyReloadFailures = errors.New("too many consecutive reload failures")
+)
+
+// KeyPairSource reads the raw key pair from wherever it is stored, e.g. local files,
+// a secret store or a sidecar API. The loader built on top of it takes care of the
+// reload loop, checksum deduplication, validation and reload notifications.
+type KeyPairSource interface {
+	// ReadKeyPair returns the current raw key pair.
+	ReadKeyPair(ctx context.Context) (*TLSKeyPairRaw, error)
+}
+
+// WatchableKeyPairSource is implemented by sources that can tell when the key pair
+// may have changed, so that rotations are picked up without waiting for the next poll.
+type WatchableKeyPairSource interface {
+	KeyPairSource
+	// WatchKeyPair calls notify whenever the key pair may have changed,
+	// and blocks until the context is cancelled.
+	WatchKeyPair(ctx context.Context, notify func())
+}
+
+// PollableKeyPairSource is implemented by sources that can cheaply tell whether the key
+// pair may have changed since it was last read, so that polls can skip unchanged reads.
+type PollableKeyPairSource interface {
+	KeyPairSource
+	// KeyPairChanged reports whether the key pair may differ from the last one read.
+	KeyPairChanged() bool
+}
+
+type SourceTLSConfigLoaderOptions struct {
+	ReloadInterval time.Duration                   // Interval to poll the source
+	Validate       func(keyPair *TLSKeyPair) error // Validate the key pair after loading
+	PeerVerifiers  []PeerVerifier                  // Verify the peers of connections, e.g. with VerifyPeerSPIFFEID
+	OCSPStapling   *OCSPStaplingOptions            // Staple OCSP responses for the leaf; server loaders only, rejected by client loaders
+	ClientAuth     ClientAuthMode                  // Whether clients must present a certificate; server loaders only, rejected by client loaders; requires one by default
+	CryptoPolicy   *CryptoPolicy                   // Reject key pairs violating the policy; a check of the default Validate, or chained after a custom one
+	CABundlePolicy CABundlePolicy                  // Warn about, drop or reject problematic CA certificates; warns by default
+
+	MinRemainingValidity         time.Duration // Reject leaves expiring sooner in the default Validate of client and server loaders; defaults to DefaultMinRemainingValidityFraction of the lifetime, up to MinimumCertificateValidityDuration
+	MinRemainingValidityFraction float64       // Minimum remaining validity as a fraction of the leaf lifetime, e.g. 0.2; replaces MinRemainingValidity
+
+	Logger                 *slog.Logger  // Logger for reload outcomes; defaults to slog.Default()
+	OnError                func(error)   // Called with the error of every failed reload attempt
+	MaxConsecutiveFailures int           // StartLoop returns an error after this many failures in a row; 0 retries forever
+	RetryBackoff           time.Duration // Delay before retrying a failed reload, doubled on every failure
+	MaxRetryBackoff        time.Duration // Upper bound of the retry delay
+}
+
+func (opts *SourceTLSConfigLoaderOptions) defaults() error {
+	if opts.ReloadInterval == 0 {
+		opts.ReloadInterval = DefaultReloadInterval
+	}
+	if opts.Validate == nil {
+		return fmt.Errorf("validate function is nil")
+	}
+	opts.Validate = chainCryptoPolicy(opts.Validate, opts.CryptoPolicy)
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.MaxConsecutiveFailures < 0 {
+		return fmt.Errorf("max consecutive failures must not be negative")
+	}
+	if opts.RetryBackoff == 0 {
+		opts.RetryBackoff = DefaultRetryBackoff
+	}
+	if opts.MaxRetryBackoff == 0 {
+		opts.MaxRetryBackoff = max(DefaultMaxRetryBackoff, opts.RetryBackoff)
+	}
+	return nil
+}
+
+// SourceTLSConfigLoader keeps the key pair read from a KeyPairSource up to date.
+type SourceTLSConfigLoader struct {
+	source  KeyPairSource
+	options SourceTLSConfigLoaderOptions
+	store   *keyPairStore
+}
+
+func NewSourceTLSConfigLoader(
+	source KeyPairSource,
+	options SourceTLSConfigLoaderOptions,
+) (*SourceTLSConfigLoader, error) {
+	if source == nil {
+		return nil, fmt.Errorf("key pair source is nil")
+	}
+	if err := options.defaults(); err != nil {
+		return nil, err
+	}
+	loader := &SourceTLSConfigLoader{
+		source:  source,
+		options: options,
+		store:   newKeyPairStore(options.Validate, options.Logger),
+	}
+	loader.store.bundlePolicy = options.CABundlePolicy
+	if err := loader.loadKeyPair(context.Background()); err != nil {
+		return nil, err
+	}
+	return loader, nil
+}
+
+// StartLoop reloads the key pair until the context is cancelled. The source is read every
+// ReloadInterval, unless it is a PollableKeyPairSource reporting no changes, and as soon as
+// a WatchableKeyPairSource sees a change.
+func (l *SourceTLSConfigLoader) StartLoop(ctx context.Context) error {
+	loop := &reloadLoop{
+		name:                   "key pair",
+		interval:               l.options.ReloadInterval,
+		logger:                 l.options.Logger,
+		changed:                l.sourceChanged,
+		reload:                 func() error { return l.loadKeyPair(ctx) },
+		onError:                l.options.OnError,
+		maxConsecutiveFailures: l.options.MaxConsecutiveFailures,
+		retryBackoff:           l.options.RetryBackoff,
+		maxRetryBackoff:        l.options.MaxRetryBackoff,
+	}
+	if watchable, ok := l.source.(WatchableKeyPairSource); ok {
+		loop.watch = watchable.WatchKeyPair
+	}
+	return loop.run(ctx)
+}
+
+func (l *SourceTLSConfigLoader) KeyPair() *TLSKeyPair {
+	return l.store.KeyPair()
+}
+
+// Watch returns a channel of reload events, which is closed when the context is cancelled.
+func (l *SourceTLSConfigLoader) Watch(ctx context.Context) <-chan ReloadEvent {
+	return l.store.Watch(ctx)
+}
+
+func (l *SourceTLSConfigLoader) sourceChanged() bool {
+	pollable, ok := l.source.(PollableKeyPairSource)
+	return !ok || pollable.KeyPairChanged()
+}
+
+func (l *SourceTLSConfigLoader) loadKeyPair(ctx context.Context) error {
+	raw, err := l.source.ReadKeyPair(ctx)
+	if err != nil {
+		return l.store.reject(err)
+	}
+	return l.store.updateRaw(raw)
+}
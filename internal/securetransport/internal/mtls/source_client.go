@@ -0,0 +1,53 @@
+package mtls
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/grpc/credentials"
+)
+
+type SourceClientTLSConfigLoader struct {
+	loader    *SourceTLSConfigLoader
+	verifiers []PeerVerifier
+}
+
+func NewSourceClientTLSConfigLoader(
+	source KeyPairSource,
+	options SourceTLSConfigLoaderOptions,
+) (*SourceClientTLSConfigLoader, error) {
+	if options.OCSPStapling != nil || options.ClientAuth != ClientAuthDefault {
+		return nil, fmt.Errorf("OCSP stapling and client auth are server options, they must not be set on client loaders")
+	}
+	validate, err := loaderValidator(
+		options.Validate, x509.ExtKeyUsageClientAuth, options.MinRemainingValidity, options.MinRemainingValidityFraction,
+		options.CryptoPolicy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	options.Validate, options.CryptoPolicy = validate, nil // The policy is applied by validate.
+	loader, err := NewSourceTLSConfigLoader(source, options)
+	if err != nil {
+		return nil, err
+	}
+	return &SourceClientTLSConfigLoader{loader: loader, verifiers: options.PeerVerifiers}, nil
+}
+
+func (l *SourceClientTLSConfigLoader) StartLoop(ctx context.Context) error {
+	return l.loader.StartLoop(ctx)
+}
+
+func (l *SourceClientTLSConfigLoader) Watch(ctx context.Context) <-chan ReloadEvent {
+	return l.loader.Watch(ctx)
+}
+
+func (l *SourceClientTLSConfigLoader) HTTPRoundTripper() http.RoundTripper {
+	return CreateDynamicTLSTransport(l.loader, l.verifiers...)
+}
+
+func (l *SourceClientTLSConfigLoader) GRPCCredentials() credentials.TransportCredentials {
+	return CreateDynamicTLSCredentials(l.loader, l.verifiers...)
+}
@@ -0,0 +1,82 @@
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/credentials"
+)
+
+type SourceServerTLSConfigLoader struct {
+	loader     *SourceTLSConfigLoader
+	verifiers  []PeerVerifier
+	clientAuth ClientAuthMode
+	stapler    *ocspStapler
+}
+
+func NewSourceServerTLSConfigLoader(
+	source KeyPairSource,
+	options SourceTLSConfigLoaderOptions,
+) (*SourceServerTLSConfigLoader, error) {
+	validate, err := loaderValidator(
+		options.Validate, x509.ExtKeyUsageServerAuth, options.MinRemainingValidity, options.MinRemainingValidityFraction,
+		options.CryptoPolicy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	options.Validate, options.CryptoPolicy = validate, nil // The policy is applied by validate.
+	loader, err := NewSourceTLSConfigLoader(source, options)
+	if err != nil {
+		return nil, err
+	}
+	rv := &SourceServerTLSConfigLoader{loader: loader, verifiers: options.PeerVerifiers, clientAuth: options.ClientAuth}
+	if options.OCSPStapling != nil {
+		rv.stapler = newOCSPStapler(loader, *options.OCSPStapling)
+	}
+	return rv, nil
+}
+
+// StartLoop reloads the key pair, and refreshes its OCSP staple if enabled,
+// until the context is cancelled.
+func (l *SourceServerTLSConfigLoader) StartLoop(ctx context.Context) error {
+	if l.stapler == nil {
+		return l.loader.StartLoop(ctx)
+	}
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		return l.loader.StartLoop(ctx)
+	})
+	eg.Go(func() error {
+		l.stapler.Run(ctx)
+		return nil
+	})
+	return eg.Wait()
+}
+
+func (l *SourceServerTLSConfigLoader) Watch(ctx context.Context) <-chan ReloadEvent {
+	return l.loader.Watch(ctx)
+}
+
+// KeyPair returns the key pair in use, with its OCSP staple if stapling is enabled.
+func (l *SourceServerTLSConfigLoader) KeyPair() *TLSKeyPair {
+	if l.stapler != nil {
+		return l.stapler.KeyPair()
+	}
+	return l.loader.KeyPair()
+}
+
+func (l *SourceServerTLSConfigLoader) ServerTLSConfig() *tls.Config {
+	return createTLSConfigForServerLoader(l, l.clientAuth, l.verifiers...)
+}
+
+func (l *SourceServerTLSConfigLoader) GRPCServerCredentials() credentials.TransportCredentials {
+	return credentials.NewTLS(l.ServerTLSConfig())
+}
+
+// clientVerification returns how the loader verifies clients, for SNIServerTLSConfigLoader.
+func (l *SourceServerTLSConfigLoader) clientVerification() (ClientAuthMode, []PeerVerifier) {
+	return l.clientAuth, l.verifiers
+}
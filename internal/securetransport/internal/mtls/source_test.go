@@ -0,0 +1,244 @@
+package mtls
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeKeyPairSource struct {
+	mu      sync.Mutex
+	raw     *TLSKeyPairRaw
+	err     error
+	reads   int
+	changed bool
+	notify  chan struct{}
+}
+
+func (s *fakeKeyPairSource) Set(raw *TLSKeyPairRaw, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.raw, s.err, s.changed = raw, err, true
+}
+
+func (s *fakeKeyPairSource) Reads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reads
+}
+
+func (s *fakeKeyPairSource) ReadKeyPair(ctx context.Context) (*TLSKeyPairRaw, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reads++
+	s.changed = false
+	return s.raw, s.err
+}
+
+type fakePollableKeyPairSource struct {
+	*fakeKeyPairSource
+}
+
+func (s fakePollableKeyPairSource) KeyPairChanged() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.changed
+}
+
+type fakeWatchableKeyPairSource struct {
+	*fakeKeyPairSource
+}
+
+func (s fakeWatchableKeyPairSource) WatchKeyPair(ctx context.Context, notify func()) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.notify:
+			notify()
+		}
+	}
+}
+
+func TestNewSourceTLSConfigLoader(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca      = fakeCA(fakeCATemplate())
+		keyPair = ca.Sign(fakeServerTemplate())
+	)
+
+	t.Run("it should load the initial key pair", func(t *testing.T) {
+		t.Parallel()
+		source := &fakeKeyPairSource{raw: keyPair.Raw}
+		loader, err := NewSourceTLSConfigLoader(source, SourceTLSConfigLoaderOptions{
+			Validate: ValidateKeyPairForServerUsage,
+		})
+		require.NoError(t, err)
+		assert.True(t, loader.KeyPair().Equal(keyPair))
+	})
+
+	t.Run("it should fail if the source fails", func(t *testing.T) {
+		t.Parallel()
+		sourceErr := errors.New("source unavailable")
+		_, err := NewSourceTLSConfigLoader(&fakeKeyPairSource{err: sourceErr}, SourceTLSConfigLoaderOptions{
+			Validate: ValidateKeyPairForServerUsage,
+		})
+		require.ErrorIs(t, err, sourceErr)
+	})
+
+	t.Run("it should fail if the key pair is invalid", func(t *testing.T) {
+		t.Parallel()
+		source := &fakeKeyPairSource{raw: ca.Sign(fakeClientTemplate()).Raw}
+		_, err := NewSourceTLSConfigLoader(source, SourceTLSConfigLoaderOptions{
+			Validate: ValidateKeyPairForServerUsage,
+		})
+		require.ErrorIs(t, err, ErrValidateKeyPair)
+	})
+
+	t.Run("it should fail without a source or validate function", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewSourceTLSConfigLoader(nil, SourceTLSConfigLoaderOptions{
+			Validate: ValidateKeyPairForServerUsage,
+		})
+		require.Error(t, err)
+		_, err = NewSourceTLSConfigLoader(&fakeKeyPairSource{raw: keyPair.Raw}, SourceTLSConfigLoaderOptions{})
+		require.Error(t, err)
+	})
+}
+
+func TestSourceTLSConfigLoader_StartLoop(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca            = fakeCA(fakeCATemplate())
+		keyPair       = ca.Sign(fakeServerTemplate())
+		secondKeyPair = ca.Sign(fakeServerTemplate())
+	)
+
+	startLoop := func(t *testing.T, source KeyPairSource, options SourceTLSConfigLoaderOptions) *SourceTLSConfigLoader {
+		options.Validate = ValidateKeyPairForServerUsage
+		loader, err := NewSourceTLSConfigLoader(source, options)
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- loader.StartLoop(ctx) }()
+		t.Cleanup(func() {
+			cancel()
+			require.NoError(t, <-done)
+		})
+		return loader
+	}
+
+	t.Run("it should poll the source and skip unchanged key pairs", func(t *testing.T) {
+		t.Parallel()
+		source := &fakeKeyPairSource{raw: keyPair.Raw}
+		loader := startLoop(t, source, SourceTLSConfigLoaderOptions{ReloadInterval: 10 * time.Millisecond})
+		events := loader.Watch(context.Background())
+
+		assert.Eventually(t, func() bool { return source.Reads() > 3 }, time.Second, 5*time.Millisecond)
+		assert.Empty(t, events, "unchanged key pairs must not be reported")
+
+		source.Set(secondKeyPair.Raw, nil)
+		event := <-events
+		assert.True(t, event.Succeeded())
+		assert.Equal(t, uint64(2), event.Generation)
+		assert.True(t, loader.KeyPair().Equal(secondKeyPair))
+	})
+
+	t.Run("it should only read a pollable source when it changed", func(t *testing.T) {
+		t.Parallel()
+		source := &fakeKeyPairSource{raw: keyPair.Raw}
+		loader := startLoop(t, fakePollableKeyPairSource{source}, SourceTLSConfigLoaderOptions{
+			ReloadInterval: 10 * time.Millisecond,
+		})
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, 1, source.Reads())
+
+		source.Set(secondKeyPair.Raw, nil)
+		assert.Eventually(t, func() bool { return loader.KeyPair().Equal(secondKeyPair) }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, 2, source.Reads())
+	})
+
+	t.Run("it should reload a watchable source on notification", func(t *testing.T) {
+		t.Parallel()
+		source := &fakeKeyPairSource{raw: keyPair.Raw, notify: make(chan struct{})}
+		loader := startLoop(t, fakeWatchableKeyPairSource{source}, SourceTLSConfigLoaderOptions{
+			ReloadInterval: time.Hour,
+		})
+
+		source.Set(secondKeyPair.Raw, nil)
+		source.notify <- struct{}{}
+		assert.Eventually(t, func() bool { return loader.KeyPair().Equal(secondKeyPair) }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("it should keep the current key pair when the source fails", func(t *testing.T) {
+		t.Parallel()
+		var (
+			source    = &fakeKeyPairSource{raw: keyPair.Raw}
+			sourceErr = errors.New("source unavailable")
+			errs      = make(chan error, 16)
+		)
+		loader := startLoop(t, source, SourceTLSConfigLoaderOptions{
+			ReloadInterval: 10 * time.Millisecond,
+			RetryBackoff:   10 * time.Millisecond,
+			OnError: func(err error) {
+				select {
+				case errs <- err:
+				default:
+				}
+			},
+		})
+		events := loader.Watch(context.Background())
+
+		source.Set(nil, sourceErr)
+		require.ErrorIs(t, <-errs, sourceErr)
+		event := <-events
+		assert.False(t, event.Succeeded())
+		assert.ErrorIs(t, event.Err, sourceErr)
+		assert.True(t, loader.KeyPair().Equal(keyPair))
+
+		source.Set(secondKeyPair.Raw, nil)
+		assert.Eventually(t, func() bool { return loader.KeyPair().Equal(secondKeyPair) }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("it should give up after too many consecutive failures", func(t *testing.T) {
+		t.Parallel()
+		source := &fakeKeyPairSource{raw: keyPair.Raw}
+		loader, err := NewSourceTLSConfigLoader(source, SourceTLSConfigLoaderOptions{
+			ReloadInterval:         10 * time.Millisecond,
+			RetryBackoff:           time.Millisecond,
+			MaxConsecutiveFailures: 3,
+			Validate:               ValidateKeyPairForServerUsage,
+		})
+		require.NoError(t, err)
+
+		source.Set(nil, errors.New("source unavailable"))
+		err = loader.StartLoop(context.Background())
+		require.ErrorIs(t, err, ErrTooManyReloadFailures)
+		assert.True(t, loader.KeyPair().Equal(keyPair))
+	})
+}
+
+func TestNewSourceClientTLSConfigLoader(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca     = fakeCA(fakeCATemplate())
+		source = &fakeKeyPairSource{raw: ca.Sign(fakeClientTemplate()).Raw}
+	)
+
+	_, err := NewSourceClientTLSConfigLoader(source, SourceTLSConfigLoaderOptions{})
+	require.NoError(t, err)
+
+	_, err = NewSourceClientTLSConfigLoader(source, SourceTLSConfigLoaderOptions{ClientAuth: VerifyClientCertIfGiven})
+	assert.ErrorContains(t, err, "server options")
+	_, err = NewSourceClientTLSConfigLoader(source, SourceTLSConfigLoaderOptions{OCSPStapling: &OCSPStaplingOptions{}})
+	assert.ErrorContains(t, err, "server options")
+}
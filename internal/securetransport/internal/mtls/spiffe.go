@@ -0,0 +1,136 @@
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+var ErrInvalidSPIFFEID = errors.New("invalid SPIFFE ID")
+
+// SPIFFEID is a SPIFFE identity of the form spiffe://trust-domain/path.
+type SPIFFEID struct {
+	TrustDomain string
+	Path        string // Path is empty or starts with a slash
+}
+
+func (id SPIFFEID) String() string {
+	return "spiffe://" + id.TrustDomain + id.Path
+}
+
+// ParseSPIFFEID parses a SPIFFE ID as defined by the SPIFFE ID specification.
+func ParseSPIFFEID(raw string) (SPIFFEID, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return SPIFFEID{}, fmt.Errorf("%w: %w", ErrInvalidSPIFFEID, err)
+	}
+	switch {
+	case u.Scheme != "spiffe":
+		return SPIFFEID{}, fmt.Errorf("%w: %q: scheme must be spiffe", ErrInvalidSPIFFEID, raw)
+	case u.User != nil || u.Port() != "":
+		return SPIFFEID{}, fmt.Errorf("%w: %q: must not have user info or port", ErrInvalidSPIFFEID, raw)
+	case u.RawQuery != "" || u.Fragment != "" || u.Opaque != "":
+		return SPIFFEID{}, fmt.Errorf("%w: %q: must not have a query or fragment", ErrInvalidSPIFFEID, raw)
+	case !isSPIFFEName(u.Host, "abcdefghijklmnopqrstuvwxyz0123456789.-_"):
+		return SPIFFEID{}, fmt.Errorf("%w: %q: invalid trust domain", ErrInvalidSPIFFEID, raw)
+	}
+	if u.Path != "" {
+		for _, segment := range strings.Split(u.Path[1:], "/") {
+			if segment == "." || segment == ".." ||
+				!isSPIFFEName(segment, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789.-_") {
+				return SPIFFEID{}, fmt.Errorf("%w: %q: invalid path segment %q", ErrInvalidSPIFFEID, raw, segment)
+			}
+		}
+	}
+	return SPIFFEID{TrustDomain: u.Host, Path: u.Path}, nil
+}
+
+func isSPIFFEName(s, allowed string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune(allowed, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// PeerSPIFFEID returns the SPIFFE ID of the peer certificate of a connection.
+// The certificate must carry exactly one spiffe URI SAN.
+func PeerSPIFFEID(state tls.ConnectionState) (SPIFFEID, error) {
+	if len(state.PeerCertificates) == 0 {
+		return SPIFFEID{}, fmt.Errorf("no peer certificate")
+	}
+	return certificateSPIFFEID(state.PeerCertificates[0])
+}
+
+func certificateSPIFFEID(cert *x509.Certificate) (SPIFFEID, error) {
+	var ids []SPIFFEID
+	for _, uri := range cert.URIs {
+		if uri.Scheme != "spiffe" {
+			continue
+		}
+		id, err := ParseSPIFFEID(uri.String())
+		if err != nil {
+			return SPIFFEID{}, err
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) != 1 {
+		return SPIFFEID{}, fmt.Errorf("expected exactly one SPIFFE ID in the peer certificate, found %d", len(ids))
+	}
+	return ids[0], nil
+}
+
+// VerifyPeerSPIFFEID returns a PeerVerifier that accepts peers whose SPIFFE ID matches
+// any of the patterns.
+//
+// A pattern is a SPIFFE ID whose path may use the * and ? wildcards of path.Match, e.g.
+// spiffe://example.org/ns/*/sa/client. A pattern without a path accepts any SPIFFE ID
+// of its trust domain. Trust domains are matched exactly.
+func VerifyPeerSPIFFEID(patterns ...string) (PeerVerifier, error) {
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no SPIFFE ID patterns")
+	}
+	matchers := make([]SPIFFEID, 0, len(patterns))
+	for _, pattern := range patterns {
+		// Validate the pattern with the wildcards replaced by a valid segment character.
+		id, err := ParseSPIFFEID(strings.NewReplacer("*", "x", "?", "x").Replace(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("SPIFFE ID pattern: %w", err)
+		}
+		if !strings.HasPrefix(pattern, "spiffe://"+id.TrustDomain) {
+			return nil, fmt.Errorf("SPIFFE ID pattern %q: wildcards are not allowed in the trust domain", pattern)
+		}
+		id.Path = strings.TrimPrefix(pattern, "spiffe://"+id.TrustDomain)
+		if _, err := path.Match(id.Path, ""); err != nil {
+			return nil, fmt.Errorf("SPIFFE ID pattern %q: %w", pattern, err)
+		}
+		matchers = append(matchers, id)
+	}
+
+	return func(state tls.ConnectionState) error {
+		id, err := PeerSPIFFEID(state)
+		if err != nil {
+			return err
+		}
+		for _, matcher := range matchers {
+			if matcher.TrustDomain != id.TrustDomain {
+				continue
+			}
+			if matcher.Path == "" {
+				return nil
+			}
+			if ok, _ := path.Match(matcher.Path, id.Path); ok {
+				return nil
+			}
+		}
+		return fmt.Errorf("SPIFFE ID %s is not allowed", id)
+	}, nil
+}
@@ -0,0 +1,178 @@
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withSPIFFEID(ids ...string) func(template *x509.Certificate) {
+	return func(template *x509.Certificate) {
+		for _, id := range ids {
+			uri, err := url.Parse(id)
+			PanicIfErr(err)
+			template.URIs = append(template.URIs, uri)
+		}
+	}
+}
+
+func TestParseSPIFFEID(t *testing.T) {
+	t.Parallel()
+
+	t.Run("it should parse valid SPIFFE IDs", func(t *testing.T) {
+		t.Parallel()
+		id, err := ParseSPIFFEID("spiffe://example.org/ns/default/sa/client")
+		require.NoError(t, err)
+		assert.Equal(t, SPIFFEID{TrustDomain: "example.org", Path: "/ns/default/sa/client"}, id)
+		assert.Equal(t, "spiffe://example.org/ns/default/sa/client", id.String())
+
+		id, err = ParseSPIFFEID("spiffe://example.org")
+		require.NoError(t, err)
+		assert.Equal(t, SPIFFEID{TrustDomain: "example.org"}, id)
+	})
+
+	t.Run("it should reject invalid SPIFFE IDs", func(t *testing.T) {
+		t.Parallel()
+		for _, raw := range []string{
+			"https://example.org/workload",
+			"spiffe://Example.org/workload",
+			"spiffe:///workload",
+			"spiffe://example.org:8080/workload",
+			"spiffe://user@example.org/workload",
+			"spiffe://example.org/workload?query",
+			"spiffe://example.org/workload#fragment",
+			"spiffe://example.org/",
+			"spiffe://example.org//workload",
+			"spiffe://example.org/ns/../workload",
+			"spiffe://example.org/work*load",
+		} {
+			_, err := ParseSPIFFEID(raw)
+			assert.ErrorIs(t, err, ErrInvalidSPIFFEID, raw)
+		}
+	})
+}
+
+func TestVerifyPeerSPIFFEID(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca    = fakeCA(fakeCATemplate())
+		state = func(ids ...string) tls.ConnectionState {
+			keyPair := ca.Sign(fakeClientTemplate(withSPIFFEID(ids...)))
+			return tls.ConnectionState{PeerCertificates: []*x509.Certificate{keyPair.Certificate.Leaf}}
+		}
+	)
+
+	t.Run("it should match exact IDs and path patterns", func(t *testing.T) {
+		t.Parallel()
+		verify, err := VerifyPeerSPIFFEID(
+			"spiffe://example.org/ns/default/sa/client",
+			"spiffe://example.org/ns/*/sa/admin",
+			"spiffe://partner.org",
+		)
+		require.NoError(t, err)
+
+		assert.NoError(t, verify(state("spiffe://example.org/ns/default/sa/client")))
+		assert.NoError(t, verify(state("spiffe://example.org/ns/ops/sa/admin")))
+		assert.NoError(t, verify(state("spiffe://partner.org/any/workload")))
+
+		assert.ErrorContains(t, verify(state("spiffe://example.org/ns/other/sa/client")), "is not allowed")
+		assert.ErrorContains(t, verify(state("spiffe://example.org/ns/a/b/sa/admin")), "is not allowed")
+		assert.ErrorContains(t, verify(state("spiffe://evil.org/ns/default/sa/client")), "is not allowed")
+	})
+
+	t.Run("it should require exactly one SPIFFE ID", func(t *testing.T) {
+		t.Parallel()
+		verify, err := VerifyPeerSPIFFEID("spiffe://example.org")
+		require.NoError(t, err)
+
+		assert.ErrorContains(t, verify(state()), "found 0")
+		assert.ErrorContains(t, verify(state("spiffe://example.org/a", "spiffe://example.org/b")), "found 2")
+		assert.ErrorContains(t, verify(tls.ConnectionState{}), "no peer certificate")
+		assert.NoError(t, verify(state("https://example.org/ignored", "spiffe://example.org/a")))
+	})
+
+	t.Run("it should reject invalid patterns", func(t *testing.T) {
+		t.Parallel()
+		for _, pattern := range []string{
+			"spiffe://*.example.org/workload",
+			"https://example.org/workload",
+			"spiffe://example.org/work[load",
+		} {
+			_, err := VerifyPeerSPIFFEID(pattern)
+			assert.Error(t, err, pattern)
+		}
+		_, err := VerifyPeerSPIFFEID()
+		assert.Error(t, err)
+	})
+}
+
+func TestSPIFFEIDPeerVerification(t *testing.T) {
+	t.Parallel()
+
+	const ServerName = "test-server"
+
+	var (
+		ca            = fakeCA(fakeCATemplate())
+		serverKeyPair = ca.Sign(fakeServerTemplate(func(template *x509.Certificate) {
+			template.DNSNames = []string{ServerName}
+			template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		}, withSPIFFEID("spiffe://example.org/ns/default/sa/server")))
+		clientKeyPair = ca.Sign(fakeClientTemplate(withSPIFFEID("spiffe://example.org/ns/default/sa/client")))
+	)
+
+	verifier := func(pattern string) PeerVerifier {
+		verify, err := VerifyPeerSPIFFEID(pattern)
+		require.NoError(t, err)
+		return verify
+	}
+	get := func(serverVerifier, clientVerifier PeerVerifier) error {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		server.TLS = CreateTLSConfigForServer(&fakeKeyPairLoader{keyPair: serverKeyPair}, serverVerifier)
+		server.StartTLS()
+		defer server.Close()
+
+		client := http.Client{
+			Transport: CreateDynamicTLSTransport(&fakeKeyPairLoader{keyPair: clientKeyPair}, clientVerifier),
+		}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	t.Run("it should accept allowed peers on both sides", func(t *testing.T) {
+		t.Parallel()
+		require.NoError(t, get(
+			verifier("spiffe://example.org/ns/default/sa/client"),
+			verifier("spiffe://example.org/ns/default/sa/server"),
+		))
+	})
+
+	t.Run("it should reject a client that is not allowed by the server", func(t *testing.T) {
+		t.Parallel()
+		require.Error(t, get(
+			verifier("spiffe://example.org/ns/default/sa/other"),
+			verifier("spiffe://example.org/ns/default/sa/server"),
+		))
+	})
+
+	t.Run("it should reject a server that is not allowed by the client", func(t *testing.T) {
+		t.Parallel()
+		err := get(
+			verifier("spiffe://example.org/ns/default/sa/client"),
+			verifier("spiffe://example.org/ns/default/sa/other"),
+		)
+		require.ErrorIs(t, err, ErrVerifyPeer)
+	})
+}
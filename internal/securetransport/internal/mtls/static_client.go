@@ -0,0 +1,28 @@
+package mtls
+
+import (
+	"net/http"
+
+	"google.golang.org/grpc/credentials"
+)
+
+type StaticClientTLSConfigLoader struct {
+	*StaticTLSConfigLoader
+	verifiers []PeerVerifier
+}
+
+func NewStaticClientTLSConfigLoader(keyPair *TLSKeyPair, verifiers ...PeerVerifier) (*StaticClientTLSConfigLoader, error) {
+	loader, err := NewStaticTLSConfigLoader(keyPair, ValidateKeyPairForClientUsage)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticClientTLSConfigLoader{StaticTLSConfigLoader: loader, verifiers: verifiers}, nil
+}
+
+func (l *StaticClientTLSConfigLoader) HTTPRoundTripper() http.RoundTripper {
+	return CreateDynamicTLSTransport(l, l.verifiers...)
+}
+
+func (l *StaticClientTLSConfigLoader) GRPCCredentials() credentials.TransportCredentials {
+	return CreateDynamicTLSCredentials(l, l.verifiers...)
+}
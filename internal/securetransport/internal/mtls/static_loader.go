@@ -0,0 +1,55 @@
+package mtls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrKeyPairWithoutRaw = errors.New("key pair has no raw PEM")
+
+// StaticTLSConfigLoader holds a key pair in memory. New key pairs are pushed with Set
+// instead of being read from files, e.g. in tests or when they come from another system.
+type StaticTLSConfigLoader struct {
+	store *keyPairStore
+}
+
+func NewStaticTLSConfigLoader(
+	keyPair *TLSKeyPair,
+	validate func(keyPair *TLSKeyPair) error,
+) (*StaticTLSConfigLoader, error) {
+	if validate == nil {
+		return nil, fmt.Errorf("validate function is nil")
+	}
+	loader := &StaticTLSConfigLoader{store: newKeyPairStore(validate, nil)}
+	if err := loader.Set(keyPair); err != nil {
+		return nil, err
+	}
+	return loader, nil
+}
+
+// StartLoop blocks until the context is cancelled; a static loader has nothing to reload.
+func (l *StaticTLSConfigLoader) StartLoop(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (l *StaticTLSConfigLoader) KeyPair() *TLSKeyPair {
+	return l.store.KeyPair()
+}
+
+// Watch returns a channel of reload events, which is closed when the context is cancelled.
+func (l *StaticTLSConfigLoader) Watch(ctx context.Context) <-chan ReloadEvent {
+	return l.store.Watch(ctx)
+}
+
+// Set validates the key pair and swaps it in. The key pair must carry its raw PEM,
+// as returned by TLSKeyPairRaw.Parse. Setting the key pair in use is a no-op.
+func (l *StaticTLSConfigLoader) Set(keyPair *TLSKeyPair) error {
+	return l.store.update(keyPair)
+}
+
+// SetPEM parses the PEM encoded key pair and swaps it in like Set.
+func (l *StaticTLSConfigLoader) SetPEM(caPEM, certPEM, keyPEM []byte) error {
+	return l.store.updateRaw(NewTLSKeyPairRaw(caPEM, certPEM, keyPEM))
+}
@@ -0,0 +1,112 @@
+package mtls
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticTLSConfigLoader_Set(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca            = fakeCA(fakeCATemplate())
+		keyPair       = ca.Sign(fakeServerTemplate())
+		secondKeyPair = ca.Sign(fakeServerTemplate())
+	)
+
+	loader, err := NewStaticTLSConfigLoader(keyPair, ValidateKeyPairForServerUsage)
+	require.NoError(t, err)
+	assert.Same(t, keyPair, loader.KeyPair())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := loader.Watch(ctx)
+
+	t.Log("setting the same key pair is a no-op")
+	require.NoError(t, loader.Set(keyPair))
+	assert.Empty(t, events)
+
+	t.Log("a valid key pair is swapped in")
+	require.NoError(t, loader.Set(secondKeyPair))
+	assert.Same(t, secondKeyPair, loader.KeyPair())
+	event := <-events
+	assert.True(t, event.Succeeded())
+	assert.Equal(t, uint64(2), event.Generation)
+	assert.Same(t, keyPair, event.Previous)
+
+	t.Log("an invalid key pair is rejected")
+	clientKeyPair := ca.Sign(fakeClientTemplate())
+	err = loader.Set(clientKeyPair)
+	require.ErrorIs(t, err, ErrValidateKeyPair)
+	assert.Same(t, secondKeyPair, loader.KeyPair())
+	event = <-events
+	assert.False(t, event.Succeeded())
+	assert.Same(t, clientKeyPair, event.Rejected)
+
+	t.Log("a key pair without raw PEM is rejected")
+	require.ErrorIs(t, loader.Set(&TLSKeyPair{Certificate: keyPair.Certificate, CAs: keyPair.CAs}), ErrKeyPairWithoutRaw)
+	assert.Same(t, secondKeyPair, loader.KeyPair())
+
+	t.Log("a PEM encoded key pair is parsed and swapped in")
+	require.NoError(t, loader.SetPEM(
+		ToCertificatePEM(ca.Certificate.Raw),
+		ToCertificatePEM(keyPair.Certificate.Leaf.Raw),
+		ToPrivateKeyPEM(keyPair.Certificate.PrivateKey),
+	))
+	assert.True(t, loader.KeyPair().Equal(keyPair))
+}
+
+func TestStaticTLSLoaders(t *testing.T) {
+	t.Parallel()
+
+	const ServerName = "test-server"
+
+	var (
+		ca             = fakeCA(fakeCATemplate())
+		serverTemplate = func(template *x509.Certificate) {
+			template.Subject.CommonName = ServerName
+			template.DNSNames = []string{ServerName}
+			template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		}
+		serverKeyPair = ca.Sign(fakeServerTemplate(serverTemplate))
+		clientKeyPair = ca.Sign(fakeClientTemplate())
+	)
+
+	_, err := NewStaticClientTLSConfigLoader(serverKeyPair)
+	require.ErrorContains(t, err, "certificate is not valid for client usage")
+	_, err = NewStaticServerTLSConfigLoader(clientKeyPair)
+	require.ErrorContains(t, err, "certificate is not valid for server usage")
+
+	serverLoader, err := NewStaticServerTLSConfigLoader(serverKeyPair)
+	require.NoError(t, err)
+	clientLoader, err := NewStaticClientTLSConfigLoader(clientKeyPair)
+	require.NoError(t, err)
+	require.NotNil(t, clientLoader.GRPCCredentials())
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = serverLoader.ServerTLSConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	client := http.Client{Transport: clientLoader.HTTPRoundTripper()}
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Move the server to a CA the client doesn't trust.
+	otherCA := fakeCA(fakeCATemplate())
+	require.NoError(t, serverLoader.Set(otherCA.Sign(fakeServerTemplate(serverTemplate))))
+	client.CloseIdleConnections()
+	_, err = client.Get(server.URL)
+	require.Error(t, err)
+}
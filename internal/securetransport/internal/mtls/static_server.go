@@ -0,0 +1,33 @@
+package mtls
+
+import (
+	"crypto/tls"
+
+	"google.golang.org/grpc/credentials"
+)
+
+type StaticServerTLSConfigLoader struct {
+	*StaticTLSConfigLoader
+	verifiers []PeerVerifier
+}
+
+func NewStaticServerTLSConfigLoader(keyPair *TLSKeyPair, verifiers ...PeerVerifier) (*StaticServerTLSConfigLoader, error) {
+	loader, err := NewStaticTLSConfigLoader(keyPair, ValidateKeyPairForServerUsage)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticServerTLSConfigLoader{StaticTLSConfigLoader: loader, verifiers: verifiers}, nil
+}
+
+func (l *StaticServerTLSConfigLoader) ServerTLSConfig() *tls.Config {
+	return CreateTLSConfigForServer(l, l.verifiers...)
+}
+
+func (l *StaticServerTLSConfigLoader) GRPCServerCredentials() credentials.TransportCredentials {
+	return credentials.NewTLS(l.ServerTLSConfig())
+}
+
+// clientVerification leaves the client auth to SNIServerTLSConfigLoader, which requires a certificate by default.
+func (l *StaticServerTLSConfigLoader) clientVerification() (ClientAuthMode, []PeerVerifier) {
+	return ClientAuthDefault, l.verifiers
+}
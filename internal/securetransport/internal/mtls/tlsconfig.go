@@ -2,16 +2,80 @@ package mtls
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 )
 
-func CreateTLSConfigForServer(loader interface{ KeyPair() *TLSKeyPair }) *tls.Config {
-	getConfigForClient := func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+// ClientAuthMode is whether a server asks clients for certificates. Certificates that
+// clients present are always verified.
+type ClientAuthMode int
+
+const (
+	ClientAuthDefault       ClientAuthMode = iota // Unset: RequireClientCert, or the mode of the loader of an SNI identity
+	RequireClientCert                             // Reject clients without a valid certificate
+	VerifyClientCertIfGiven                       // Verify the certificates of clients that present one
+	NoClientCert                                  // Don't ask clients for certificates
+)
+
+func (m ClientAuthMode) tlsClientAuth() tls.ClientAuthType {
+	switch m {
+	case VerifyClientCertIfGiven:
+		return tls.VerifyClientCertIfGiven
+	case NoClientCert:
+		return tls.NoClientCert
+	default:
+		return tls.RequireAndVerifyClientCert
+	}
+}
+
+// serverHandshake is the key pair a server presents in a handshake, and how it verifies
+// the client.
+type serverHandshake struct {
+	keyPair    *TLSKeyPair
+	clientCAs  *x509.CertPool
+	clientAuth ClientAuthMode
+	verify     func(state tls.ConnectionState) error // verify is the result of verifyClient
+}
+
+// CreateTLSConfigForServer returns a server config that requires client certificates signed
+// by the CA bundle of the loader, and checks the clients with the verifiers.
+func CreateTLSConfigForServer(loader interface{ KeyPair() *TLSKeyPair }, verifiers ...PeerVerifier) *tls.Config {
+	return createTLSConfigForServerLoader(loader, RequireClientCert, verifiers...)
+}
+
+func createTLSConfigForServerLoader(loader interface{ KeyPair() *TLSKeyPair }, clientAuth ClientAuthMode, verifiers ...PeerVerifier) *tls.Config {
+	verify := verifyClient(verifiers)
+	return createTLSConfigForServer(func(*tls.ClientHelloInfo) serverHandshake {
 		keyPair := loader.KeyPair()
+		return serverHandshake{keyPair: keyPair, clientCAs: keyPair.CAs, clientAuth: clientAuth, verify: verify}
+	})
+}
+
+// verifyClient combines the verifiers of a server. They only check clients presenting a
+// certificate; whether one is required is up to the ClientAuthMode.
+func verifyClient(verifiers []PeerVerifier) func(state tls.ConnectionState) error {
+	verify := verifyConnection(verifiers)
+	if verify == nil {
+		return nil
+	}
+	return func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 {
+			return nil
+		}
+		return verify(state)
+	}
+}
+
+// createTLSConfigForServer is CreateTLSConfigForServer with the key pair and client
+// verification selected for each client hello.
+func createTLSConfigForServer(selectHandshake func(info *tls.ClientHelloInfo) serverHandshake) *tls.Config {
+	getConfigForClient := func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+		handshake := selectHandshake(info)
 		return &tls.Config{
-			ClientAuth: tls.RequireAndVerifyClientCert,
-			ClientCAs:  keyPair.CAs,
+			ClientAuth:       handshake.clientAuth.tlsClientAuth(),
+			ClientCAs:        handshake.clientCAs,
+			VerifyConnection: handshake.verify,
 			GetCertificate: func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
-				return keyPair.Certificate, nil
+				return handshake.keyPair.Certificate, nil
 			},
 		}, nil
 	}
@@ -7,60 +7,196 @@ import (
 	"time"
 )
 
-const MinimumCertificateValidityDuration = 10 * time.Minute
+const (
+	// MinimumCertificateValidityDuration caps the remaining validity the loaders require by default.
+	MinimumCertificateValidityDuration = 10 * time.Minute
+	// DefaultMinRemainingValidityFraction is the fraction of the lifetime of the leaf the loaders require by default.
+	DefaultMinRemainingValidityFraction = 0.2
+)
+
+// KeyPairValidator checks a key pair before it is swapped in. It can be used as the
+// Validate option of the loaders.
+type KeyPairValidator func(keyPair *TLSKeyPair) error
+
+// ValidationCheck is a named check of a key pair, composed into a KeyPairValidator with
+// ComposeValidators.
+type ValidationCheck struct {
+	Name  string                          // Name of the check in the ValidationReport
+	Check func(keyPair *TLSKeyPair) error // Check returns why the key pair fails the check
+}
+
+// ComposeValidators returns a validator that makes sure the key pair has a certificate
+// and a CA pool, and then runs all the checks. If any of them fails, it returns a
+// *ValidationReport with the outcome of every check.
+func ComposeValidators(checks ...ValidationCheck) KeyPairValidator {
+	return func(keyPair *TLSKeyPair) error {
+		report := &ValidationReport{}
+		if err := checkKeyPair(keyPair); err != nil {
+			report.Results = append(report.Results, ValidationResult{Name: "key-pair", Err: err})
+			return report
+		}
+		report.Subject = keyPair.Certificate.Leaf.Subject.String()
+		for _, check := range checks {
+			report.Results = append(report.Results, ValidationResult{Name: check.Name, Err: check.Check(keyPair)})
+		}
+		if len(report.Failed()) == 0 {
+			return nil
+		}
+		return report
+	}
+}
 
-func validateKeyPair(keyPair *TLSKeyPair) error {
+func checkKeyPair(keyPair *TLSKeyPair) error {
+	if keyPair == nil {
+		return fmt.Errorf("key pair is nil")
+	}
 	if keyPair.Certificate == nil {
 		return fmt.Errorf("certificate is nil")
 	}
 	if keyPair.CAs == nil {
 		return fmt.Errorf("CA pool is nil")
 	}
-	now := time.Now()
-	if keyPair.Certificate.Leaf.NotBefore.After(now) {
-		return fmt.Errorf("certificate is not valid yet: %s", keyPair.Certificate.Leaf.NotBefore)
-	}
-	if keyPair.Certificate.Leaf.NotAfter.Before(now.Add(MinimumCertificateValidityDuration)) {
-		return fmt.Errorf("certificate will expire in less than %s", MinimumCertificateValidityDuration)
+	if keyPair.Certificate.Leaf == nil {
+		return fmt.Errorf("leaf certificate is nil")
 	}
-
 	return nil
 }
 
-func ValidateKeyPairForServerUsage(keyPair *TLSKeyPair) error {
-	if err := validateKeyPair(keyPair); err != nil {
-		return err
-	}
-	if !slices.Contains(keyPair.Certificate.Leaf.ExtKeyUsage, x509.ExtKeyUsageServerAuth) {
-		return fmt.Errorf("certificate is not valid for server usage")
-	}
-	verifyOptions := x509.VerifyOptions{
-		Roots: keyPair.CAs,
-		KeyUsages: []x509.ExtKeyUsage{
-			x509.ExtKeyUsageServerAuth,
-		},
+// ValidateExpiry rejects leaves that are not valid yet, or that expire within minRemaining.
+func ValidateExpiry(minRemaining time.Duration) ValidationCheck {
+	return ValidationCheck{Name: "expiry", Check: func(keyPair *TLSKeyPair) error {
+		return validateExpiry(keyPair.Certificate.Leaf, minRemaining)
+	}}
+}
+
+// ValidateExpiryFraction rejects leaves that are not valid yet, or that have less than the
+// fraction of their total lifetime left. A fraction of 0.2 accepts a 10 minute certificate
+// until 2 minutes before it expires, and a 90 day certificate until 18 days before.
+func ValidateExpiryFraction(fraction float64) ValidationCheck {
+	return ValidationCheck{Name: "expiry", Check: func(keyPair *TLSKeyPair) error {
+		leaf := keyPair.Certificate.Leaf
+		lifetime := leaf.NotAfter.Sub(leaf.NotBefore)
+		return validateExpiry(leaf, time.Duration(float64(lifetime)*fraction))
+	}}
+}
+
+// validateDefaultExpiry is the expiry check of the loaders when no minimum remaining
+// validity is set. Leaves must have DefaultMinRemainingValidityFraction of their lifetime
+// left, up to MinimumCertificateValidityDuration, so that short-lived certificates are
+// accepted as soon as they are issued.
+func validateDefaultExpiry() ValidationCheck {
+	return ValidationCheck{Name: "expiry", Check: func(keyPair *TLSKeyPair) error {
+		leaf := keyPair.Certificate.Leaf
+		lifetime := leaf.NotAfter.Sub(leaf.NotBefore)
+		return validateExpiry(leaf, min(time.Duration(float64(lifetime)*DefaultMinRemainingValidityFraction), MinimumCertificateValidityDuration))
+	}}
+}
+
+func validateExpiry(leaf *x509.Certificate, minRemaining time.Duration) error {
+	now := time.Now()
+	if leaf.NotBefore.After(now) {
+		return fmt.Errorf("certificate is not valid yet: %s", leaf.NotBefore)
 	}
-	if _, err := keyPair.Certificate.Leaf.Verify(verifyOptions); err != nil {
-		return fmt.Errorf("verify certificate signature: %w", err)
+	if leaf.NotAfter.Before(now.Add(minRemaining)) {
+		return fmt.Errorf("certificate will expire in less than %s", minRemaining)
 	}
 	return nil
 }
 
-func ValidateKeyPairForClientUsage(keyPair *TLSKeyPair) error {
-	if err := validateKeyPair(keyPair); err != nil {
-		return err
+// ValidateExtKeyUsage rejects leaves that don't list the extended key usage.
+func ValidateExtKeyUsage(usage x509.ExtKeyUsage) ValidationCheck {
+	return ValidationCheck{Name: "ext-key-usage", Check: func(keyPair *TLSKeyPair) error {
+		if !slices.Contains(keyPair.Certificate.Leaf.ExtKeyUsage, usage) {
+			return fmt.Errorf("certificate is not valid for %s usage", extKeyUsageName(usage))
+		}
+		return nil
+	}}
+}
+
+func extKeyUsageName(usage x509.ExtKeyUsage) string {
+	switch usage {
+	case x509.ExtKeyUsageServerAuth:
+		return "server"
+	case x509.ExtKeyUsageClientAuth:
+		return "client"
+	default:
+		return fmt.Sprintf("extended key usage %d", usage)
 	}
-	if !slices.Contains(keyPair.Certificate.Leaf.ExtKeyUsage, x509.ExtKeyUsageClientAuth) {
-		return fmt.Errorf("certificate is not valid for client usage")
+}
+
+// ValidateChain rejects leaves that don't chain up to the CA bundle for the usage, through
+// the intermediates presented after the leaf.
+func ValidateChain(usage x509.ExtKeyUsage) ValidationCheck {
+	return ValidationCheck{Name: "chain", Check: func(keyPair *TLSKeyPair) error {
+		if _, err := verifyCertificateChain(keyPair, usage); err != nil {
+			return fmt.Errorf("verify certificate signature: %w", err)
+		}
+		return nil
+	}}
+}
+
+// ValidateSANCoverage rejects leaves that are not valid for every one of the host names
+// or IP addresses, e.g. the names a server is reached by.
+func ValidateSANCoverage(names ...string) ValidationCheck {
+	return ValidationCheck{Name: "san-coverage", Check: func(keyPair *TLSKeyPair) error {
+		for _, name := range names {
+			if err := keyPair.Certificate.Leaf.VerifyHostname(name); err != nil {
+				return fmt.Errorf("certificate does not cover %q: %w", name, err)
+			}
+		}
+		return nil
+	}}
+}
+
+// usageValidator returns the expiry check followed by the usage and chain checks.
+func usageValidator(usage x509.ExtKeyUsage, expiry ValidationCheck) KeyPairValidator {
+	return ComposeValidators(expiry, ValidateExtKeyUsage(usage), ValidateChain(usage))
+}
+
+// loaderValidator returns the validator of the loaders for the usage. The minimum
+// remaining validity is either absolute, a fraction of the lifetime of the leaf, or the
+// default of validateDefaultExpiry if neither is set. The crypto policy, if any, is the
+// last check, so that its failures show up in the ValidationReport with the others.
+//
+// A custom validate function must not be combined with a minimum remaining validity,
+// and is returned with the crypto policy chained after it.
+func loaderValidator(
+	validate func(keyPair *TLSKeyPair) error,
+	usage x509.ExtKeyUsage,
+	minRemaining time.Duration,
+	minRemainingFraction float64,
+	policy *CryptoPolicy,
+) (func(keyPair *TLSKeyPair) error, error) {
+	var expiry ValidationCheck
+	switch {
+	case minRemaining != 0 && minRemainingFraction != 0:
+		return nil, fmt.Errorf("min remaining validity and min remaining validity fraction must not be set together")
+	case minRemaining < 0:
+		return nil, fmt.Errorf("min remaining validity must not be negative")
+	case minRemainingFraction < 0 || minRemainingFraction >= 1:
+		return nil, fmt.Errorf("min remaining validity fraction must be in [0, 1)")
+	case minRemainingFraction != 0:
+		expiry = ValidateExpiryFraction(minRemainingFraction)
+	case minRemaining != 0:
+		expiry = ValidateExpiry(minRemaining)
+	case validate != nil:
+		return chainCryptoPolicy(validate, policy), nil
+	default:
+		expiry = validateDefaultExpiry()
 	}
-	verifyOptions := x509.VerifyOptions{
-		Roots: keyPair.CAs,
-		KeyUsages: []x509.ExtKeyUsage{
-			x509.ExtKeyUsageClientAuth,
-		},
+	if validate != nil {
+		return nil, fmt.Errorf("min remaining validity must not be set together with a validate function, use ValidateExpiry instead")
 	}
-	if _, err := keyPair.Certificate.Leaf.Verify(verifyOptions); err != nil {
-		return fmt.Errorf("verify certificate signature: %w", err)
+	if policy == nil {
+		return usageValidator(usage, expiry), nil
 	}
-	return nil
+	return ComposeValidators(expiry, ValidateExtKeyUsage(usage), ValidateChain(usage), policy.Check()), nil
+}
+
+func ValidateKeyPairForServerUsage(keyPair *TLSKeyPair) error {
+	return usageValidator(x509.ExtKeyUsageServerAuth, validateDefaultExpiry())(keyPair)
+}
+
+func ValidateKeyPairForClientUsage(keyPair *TLSKeyPair) error {
+	return usageValidator(x509.ExtKeyUsageClientAuth, validateDefaultExpiry())(keyPair)
 }
@@ -0,0 +1,86 @@
+package mtls
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// ValidationResult is the outcome of a ValidationCheck.
+type ValidationResult struct {
+	Name string // Name of the check
+	Err  error  // Err is why the check failed, nil if it passed
+}
+
+func (r ValidationResult) Passed() bool {
+	return r.Err == nil
+}
+
+// ValidationReport lists the outcome of every check of a key pair validation. It is
+// returned as the error of a validator built by ComposeValidators, and can be retrieved
+// from the errors of the loaders with errors.As.
+type ValidationReport struct {
+	Subject string             // Subject of the leaf, empty if the key pair has none
+	Results []ValidationResult // Results of the checks in the order they ran
+}
+
+// Failed returns the results of the failed checks.
+func (r *ValidationReport) Failed() []ValidationResult {
+	var rv []ValidationResult
+	for _, result := range r.Results {
+		if !result.Passed() {
+			rv = append(rv, result)
+		}
+	}
+	return rv
+}
+
+// Error lists the failed checks on a single line.
+func (r *ValidationReport) Error() string {
+	failed := r.Failed()
+	details := make([]string, 0, len(failed))
+	for _, result := range failed {
+		details = append(details, fmt.Sprintf("%s: %s", result.Name, result.Err))
+	}
+	return fmt.Sprintf("%d of %d checks failed for %q: %s",
+		len(failed), len(r.Results), r.Subject, strings.Join(details, "; "))
+}
+
+// Unwrap returns the errors of the failed checks, so that errors.Is and errors.As see
+// through the report, e.g. to ErrIncompleteCertificateChain.
+func (r *ValidationReport) Unwrap() []error {
+	var rv []error
+	for _, result := range r.Failed() {
+		rv = append(rv, result.Err)
+	}
+	return rv
+}
+
+// Diagnosis lists the outcome of every check, one per line, for printing.
+func (r *ValidationReport) Diagnosis() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Validation of %q:\n", r.Subject)
+	for _, result := range r.Results {
+		if result.Passed() {
+			fmt.Fprintf(&sb, "  PASS %s\n", result.Name)
+		} else {
+			fmt.Fprintf(&sb, "  FAIL %s: %s\n", result.Name, result.Err)
+		}
+	}
+	return sb.String()
+}
+
+// LogValue logs the outcome of every check as an attribute of a group, e.g.
+// slog.Any("validation", report), with "pass" or the reason the check failed.
+func (r *ValidationReport) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(r.Results)+1)
+	attrs = append(attrs, slog.String("subject", r.Subject))
+	for _, result := range r.Results {
+		if result.Passed() {
+			attrs = append(attrs, slog.String(result.Name, "pass"))
+		} else {
+			attrs = append(attrs, slog.String(result.Name, result.Err.Error()))
+		}
+	}
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,94 @@
+package mtls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationReport(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca      = fakeCA(fakeCATemplate())
+		otherCA = fakeCA(fakeCATemplate())
+		// A client certificate of another CA that is about to expire: everything is wrong at once.
+		signed = otherCA.Sign(fakeClientTemplate(func(cert *x509.Certificate) {
+			cert.NotBefore = time.Now().Add(-time.Hour)
+			cert.NotAfter = time.Now().Add(time.Minute)
+		}))
+		raw = NewTLSKeyPairRaw(
+			ToCertificatePEM(ca.Certificate.Raw),
+			ToCertificatePEM(signed.Certificate.Leaf.Raw),
+			ToPrivateKeyPEM(signed.Certificate.PrivateKey),
+		)
+	)
+	keyPair, err := raw.Parse()
+	require.NoError(t, err)
+
+	err = ValidateKeyPairForServerUsage(keyPair)
+	require.ErrorIs(t, err, ErrIncompleteCertificateChain)
+
+	var report *ValidationReport
+	require.ErrorAs(t, err, &report)
+	require.Len(t, report.Failed(), 3)
+	assert.Equal(t, "expiry", report.Failed()[0].Name)
+	assert.Equal(t, "ext-key-usage", report.Failed()[1].Name)
+	assert.Equal(t, "chain", report.Failed()[2].Name)
+
+	assert.ErrorContains(t, err, `3 of 3 checks failed for "CN=test-client"`)
+	assert.ErrorContains(t, err, "certificate will expire in less than")
+	assert.ErrorContains(t, err, "certificate is not valid for server usage")
+
+	diagnosis := report.Diagnosis()
+	assert.Contains(t, diagnosis, "FAIL expiry: certificate will expire in less than")
+	assert.Contains(t, diagnosis, "FAIL ext-key-usage: certificate is not valid for server usage")
+	assert.Contains(t, diagnosis, "FAIL chain: verify certificate signature")
+
+	t.Log("the report is reachable from the reload errors of the loaders")
+	_, err = NewSourceServerTLSConfigLoader(&fakeKeyPairSource{raw: raw}, SourceTLSConfigLoaderOptions{})
+	require.ErrorIs(t, err, ErrValidateKeyPair)
+	require.ErrorAs(t, err, &report)
+	assert.Len(t, report.Failed(), 3)
+
+	t.Log("the loaders log the outcome of every check when they reject a key pair")
+	var logBuf bytes.Buffer
+	_, err = NewSourceServerTLSConfigLoader(&fakeKeyPairSource{raw: raw}, SourceTLSConfigLoaderOptions{
+		Logger: slog.New(slog.NewTextHandler(&logBuf, nil)),
+	})
+	require.ErrorIs(t, err, ErrValidateKeyPair)
+	assert.Contains(t, logBuf.String(), "Rejected key pair by validation")
+	assert.Contains(t, logBuf.String(), `validation.subject="CN=test-client"`)
+	assert.Contains(t, logBuf.String(), `validation.expiry="certificate will expire in less than`)
+	assert.Contains(t, logBuf.String(), `validation.chain="`)
+}
+
+func TestComposeValidators_IncompleteKeyPair(t *testing.T) {
+	t.Parallel()
+
+	keyPair := fakeCA(fakeCATemplate()).Sign(fakeServerTemplate())
+	withoutLeaf := *keyPair
+	certificate := *keyPair.Certificate
+	certificate.Leaf = nil
+	withoutLeaf.Certificate = &certificate
+
+	for name, keyPair := range map[string]*TLSKeyPair{
+		"nil key pair":    nil,
+		"missing leaf":    &withoutLeaf,
+		"missing CA pool": {Certificate: keyPair.Certificate},
+	} {
+		t.Run("it should report a "+name+" instead of panicking", func(t *testing.T) {
+			t.Parallel()
+			err := ComposeValidators(ValidateExpiry(0), ValidateChain(x509.ExtKeyUsageServerAuth))(keyPair)
+			var report *ValidationReport
+			require.ErrorAs(t, err, &report)
+			require.Len(t, report.Failed(), 1)
+			assert.Equal(t, "key-pair", report.Failed()[0].Name)
+		})
+	}
+}
@@ -3,6 +3,8 @@ package mtls
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
+	"net"
 	"testing"
 	"time"
 
@@ -13,10 +15,16 @@ import (
 func TestValidateKeyPair(t *testing.T) {
 	t.Parallel()
 
+	validate := func(keyPair *TLSKeyPair) error {
+		validator, err := loaderValidator(nil, x509.ExtKeyUsageClientAuth, MinimumCertificateValidityDuration, 0, nil)
+		require.NoError(t, err)
+		return validator(keyPair)
+	}
+
 	t.Run("it should return an error if the certificate is nil", func(t *testing.T) {
 		t.Parallel()
 
-		err := validateKeyPair(&TLSKeyPair{Certificate: nil})
+		err := validate(&TLSKeyPair{Certificate: nil})
 		require.Error(t, err)
 		assert.ErrorContains(t, err, "certificate is nil")
 	})
@@ -24,7 +32,7 @@ func TestValidateKeyPair(t *testing.T) {
 	t.Run("it should return an error if the CA pool is nil", func(t *testing.T) {
 		t.Parallel()
 
-		err := validateKeyPair(&TLSKeyPair{Certificate: &tls.Certificate{}})
+		err := validate(&TLSKeyPair{Certificate: &tls.Certificate{}})
 		require.Error(t, err)
 		assert.ErrorContains(t, err, "CA pool is nil")
 	})
@@ -37,7 +45,7 @@ func TestValidateKeyPair(t *testing.T) {
 			cert.NotBefore = time.Now().Add(1 * time.Hour)
 		}))
 
-		err := validateKeyPair(keyPair)
+		err := validateDefaultExpiry().Check(keyPair)
 		require.Error(t, err)
 		assert.ErrorContains(t, err, "certificate is not valid yet")
 	})
@@ -50,7 +58,7 @@ func TestValidateKeyPair(t *testing.T) {
 			cert.NotAfter = time.Now().Add(1 * time.Minute)
 		}))
 
-		err := validateKeyPair(keyPair)
+		err := validate(keyPair)
 		require.Error(t, err)
 		assert.ErrorContains(t, err, "certificate will expire in less than")
 	})
@@ -135,3 +143,191 @@ func TestValidateKeyPairForClientUsage(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+func TestValidateKeyPair_IntermediateChain(t *testing.T) {
+	t.Parallel()
+
+	var (
+		root         = fakeCA(fakeCATemplate())
+		intermediate = root.SignIntermediate(fakeCATemplate(func(template *x509.Certificate) {
+			template.Subject.CommonName = "test-intermediate"
+		}))
+		server = intermediate.Sign(fakeServerTemplate())
+		client = intermediate.Sign(fakeClientTemplate())
+		parse  = func(keyPair *TLSKeyPair, chain ...*x509.Certificate) *TLSKeyPair {
+			certPEM := ToCertificatePEM(keyPair.Certificate.Leaf.Raw)
+			for _, cert := range chain {
+				certPEM = append(certPEM, ToCertificatePEM(cert.Raw)...)
+			}
+			parsed, err := NewTLSKeyPairRaw(
+				ToCertificatePEM(root.Certificate.Raw),
+				certPEM,
+				ToPrivateKeyPEM(keyPair.Certificate.PrivateKey),
+			).Parse()
+			PanicIfErr(err)
+			return parsed
+		}
+	)
+
+	t.Run("it should verify the leaf with the intermediates of the certificate file", func(t *testing.T) {
+		t.Parallel()
+
+		assert.NoError(t, ValidateKeyPairForServerUsage(parse(server, intermediate.Certificate)))
+		assert.NoError(t, ValidateKeyPairForClientUsage(parse(client, root.Certificate, intermediate.Certificate)))
+	})
+
+	t.Run("it should report an incomplete chain", func(t *testing.T) {
+		t.Parallel()
+
+		err := ValidateKeyPairForServerUsage(parse(server))
+		require.ErrorIs(t, err, ErrIncompleteCertificateChain)
+		assert.ErrorContains(t, err, "test-intermediate")
+
+		err = ValidateKeyPairForClientUsage(parse(client, root.Certificate))
+		require.ErrorIs(t, err, ErrIncompleteCertificateChain)
+	})
+
+	t.Run("it should not require the chain when the CA bundle holds the intermediate", func(t *testing.T) {
+		t.Parallel()
+
+		keyPair := parse(server)
+		keyPair.CAs.AddCert(intermediate.Certificate)
+		assert.NoError(t, ValidateKeyPairForServerUsage(keyPair))
+	})
+}
+
+func TestComposeValidators(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca       = fakeCA(fakeCATemplate())
+		keyPair  = ca.Sign(fakeServerTemplate())
+		errCheck = errors.New("custom check failed")
+		check    = func(name string, err error) ValidationCheck {
+			return ValidationCheck{Name: name, Check: func(*TLSKeyPair) error { return err }}
+		}
+	)
+
+	t.Run("it should run every check and report the failed ones", func(t *testing.T) {
+		t.Parallel()
+
+		err := ComposeValidators(check("first", nil), check("second", errCheck), check("third", nil))(keyPair)
+		require.ErrorIs(t, err, errCheck)
+
+		var report *ValidationReport
+		require.ErrorAs(t, err, &report)
+		assert.Equal(t, "CN=test-server", report.Subject)
+		assert.Equal(t, []ValidationResult{
+			{Name: "first"},
+			{Name: "second", Err: errCheck},
+			{Name: "third"},
+		}, report.Results)
+	})
+
+	t.Run("it should pass when every check passes", func(t *testing.T) {
+		t.Parallel()
+
+		assert.NoError(t, ComposeValidators(check("first", nil), check("second", nil))(keyPair))
+	})
+
+	t.Run("it should not run the checks without a certificate", func(t *testing.T) {
+		t.Parallel()
+
+		err := ComposeValidators(check("first", errCheck))(&TLSKeyPair{Certificate: nil})
+		assert.ErrorContains(t, err, "certificate is nil")
+		assert.NotErrorIs(t, err, errCheck)
+	})
+}
+
+func TestValidateExpiryFraction(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca    = fakeCA(fakeCATemplate())
+		fresh = ca.Sign(fakeServerTemplate(func(cert *x509.Certificate) {
+			cert.NotAfter = cert.NotBefore.Add(10 * time.Minute)
+		}))
+		expiring = ca.Sign(fakeServerTemplate(func(cert *x509.Certificate) {
+			cert.NotBefore = time.Now().Add(-9 * time.Minute)
+			cert.NotAfter = cert.NotBefore.Add(10 * time.Minute)
+		}))
+	)
+
+	assert.Error(t, ValidateExpiry(MinimumCertificateValidityDuration).Check(fresh), "a fresh 10 minute certificate is rejected by the absolute minimum")
+	assert.NoError(t, ValidateExpiryFraction(0.2).Check(fresh))
+	assert.ErrorContains(t, ValidateExpiryFraction(0.2).Check(expiring), "certificate will expire in less than 2m0s")
+}
+
+func TestValidateSANCoverage(t *testing.T) {
+	t.Parallel()
+
+	keyPair := fakeCA(fakeCATemplate()).Sign(fakeServerTemplate(func(cert *x509.Certificate) {
+		cert.DNSNames = []string{"api.internal", "*.api.internal"}
+		cert.IPAddresses = []net.IP{net.ParseIP("10.0.0.1")}
+	}))
+
+	assert.NoError(t, ValidateSANCoverage("api.internal", "v1.api.internal", "10.0.0.1").Check(keyPair))
+	assert.ErrorContains(t, ValidateSANCoverage("api.internal", "admin.internal").Check(keyPair), `certificate does not cover "admin.internal"`)
+}
+
+func TestLoaderValidator(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ca         = fakeCA(fakeCATemplate())
+		shortLived = ca.Sign(fakeServerTemplate(func(cert *x509.Certificate) {
+			cert.NotAfter = cert.NotBefore.Add(10 * time.Minute)
+		}))
+	)
+
+	t.Run("it should reject conflicting options", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := loaderValidator(nil, x509.ExtKeyUsageServerAuth, time.Minute, 0.2, nil)
+		assert.Error(t, err)
+		_, err = loaderValidator(nil, x509.ExtKeyUsageServerAuth, 0, 1, nil)
+		assert.Error(t, err)
+		_, err = loaderValidator(ValidateKeyPairForServerUsage, x509.ExtKeyUsageServerAuth, time.Minute, 0, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("it should apply the minimum remaining validity to the default validator", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewSourceServerTLSConfigLoader(&fakeKeyPairSource{raw: shortLived.Raw}, SourceTLSConfigLoaderOptions{
+			MinRemainingValidity: MinimumCertificateValidityDuration,
+		})
+		require.ErrorIs(t, err, ErrValidateKeyPair)
+
+		_, err = NewSourceServerTLSConfigLoader(&fakeKeyPairSource{raw: shortLived.Raw}, SourceTLSConfigLoaderOptions{
+			MinRemainingValidityFraction: 0.2,
+		})
+		require.NoError(t, err)
+
+		_, err = NewSourceServerTLSConfigLoader(&fakeKeyPairSource{raw: shortLived.Raw}, SourceTLSConfigLoaderOptions{
+			MinRemainingValidity: time.Minute,
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("it should accept fresh short-lived certificates by default", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewSourceServerTLSConfigLoader(&fakeKeyPairSource{raw: shortLived.Raw}, SourceTLSConfigLoaderOptions{})
+		require.NoError(t, err)
+
+		expiring := ca.Sign(fakeServerTemplate(func(cert *x509.Certificate) {
+			cert.NotBefore = time.Now().Add(-8 * time.Minute)
+			cert.NotAfter = cert.NotBefore.Add(10 * time.Minute)
+		}))
+		_, err = NewSourceServerTLSConfigLoader(&fakeKeyPairSource{raw: expiring.Raw}, SourceTLSConfigLoaderOptions{})
+		require.ErrorIs(t, err, ErrValidateKeyPair, "a 10 minute certificate with less than 2 minutes left is rejected")
+
+		longLived := ca.Sign(fakeServerTemplate(func(cert *x509.Certificate) {
+			cert.NotBefore = time.Now().Add(-time.Hour)
+			cert.NotAfter = time.Now().Add(5 * time.Minute)
+		}))
+		_, err = NewSourceServerTLSConfigLoader(&fakeKeyPairSource{raw: longLived.Raw}, SourceTLSConfigLoaderOptions{})
+		require.ErrorIs(t, err, ErrValidateKeyPair, "longer lived certificates need MinimumCertificateValidityDuration left")
+	})
+}
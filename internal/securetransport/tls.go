@@ -3,13 +3,25 @@ package securetransport
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"net/http"
+	"time"
 
 	"google.golang.org/grpc/credentials"
 
 	"github.com/zarvd/mtls-demo/internal/securetransport/internal/mtls"
 )
 
+var (
+	_ ClientTLSLoader = (*StaticClientTLSConfigLoader)(nil)
+	_ ServerTLSLoader = (*StaticServerTLSConfigLoader)(nil)
+	_ ClientTLSLoader = (*mtls.SourceClientTLSConfigLoader)(nil)
+	_ ServerTLSLoader = (*mtls.SourceServerTLSConfigLoader)(nil)
+	_ ServerTLSLoader = (*mtls.SNIServerTLSConfigLoader)(nil)
+	_ KeyPairLoader   = (*mtls.SourceServerTLSConfigLoader)(nil)
+	_ KeyPairLoader   = (*StaticServerTLSConfigLoader)(nil)
+)
+
 // ClientTLSConfigLoader provides an interface for loading and managing TLS configurations
 // for client connections. It supports dynamic certificate reloading without dropping
 // active connections, ensuring seamless certificate rotation and high availability.
@@ -21,6 +33,10 @@ type ClientTLSLoader interface {
 	HTTPRoundTripper() http.RoundTripper
 	// GRPCCredentials returns gRPC transport credentials with dynamic TLS configuration.
 	GRPCCredentials() credentials.TransportCredentials
+	// Watch subscribes to reload events until the provided context is cancelled.
+	// Each event reports the previous and current key pair, the generation in use
+	// and whether the new key pair was swapped in or rejected.
+	Watch(ctx context.Context) <-chan ReloadEvent
 }
 
 // ServerTLSConfigLoader provides an interface for loading and managing TLS configurations
@@ -33,9 +49,114 @@ type ServerTLSLoader interface {
 	// ServerTLSConfig returns the current TLS configuration for server connections.
 	// The configuration is automatically updated when certificates are reloaded.
 	ServerTLSConfig() *tls.Config
+	// GRPCServerCredentials returns gRPC server credentials with dynamic TLS configuration.
+	// New handshakes use the key pair and CA bundle in use at the time.
+	GRPCServerCredentials() credentials.TransportCredentials
+	// Watch subscribes to reload events until the provided context is cancelled.
+	// Each event reports the previous and current key pair, the generation in use
+	// and whether the new key pair was swapped in or rejected.
+	Watch(ctx context.Context) <-chan ReloadEvent
 }
 
-type LocalFileTLSConfigLoaderOptions = mtls.LocalFileTLSConfigLoaderOptions
+type (
+	LocalFileTLSConfigLoaderOptions = mtls.LocalFileTLSConfigLoaderOptions
+	TLSKeyPair                      = mtls.TLSKeyPair
+	TLSKeyPairRaw                   = mtls.TLSKeyPairRaw
+	ReloadEvent                     = mtls.ReloadEvent
+	PassphraseProvider              = mtls.PassphraseProvider
+	PassphraseFunc                  = mtls.PassphraseFunc
+	StaticClientTLSConfigLoader     = mtls.StaticClientTLSConfigLoader
+	StaticServerTLSConfigLoader     = mtls.StaticServerTLSConfigLoader
+	KeyPairSource                   = mtls.KeyPairSource
+	WatchableKeyPairSource          = mtls.WatchableKeyPairSource
+	PollableKeyPairSource           = mtls.PollableKeyPairSource
+	SourceTLSConfigLoaderOptions    = mtls.SourceTLSConfigLoaderOptions
+	KubernetesSecretSourceOptions   = mtls.KubernetesSecretSourceOptions
+	PeerVerifier                    = mtls.PeerVerifier
+	SPIFFEID                        = mtls.SPIFFEID
+	PeerPolicy                      = mtls.PeerPolicy
+	PeerPolicyLoader                = mtls.PeerPolicyLoader
+	PeerPolicyLoaderOptions         = mtls.PeerPolicyLoaderOptions
+	PeerPolicyReloadEvent           = mtls.PeerPolicyReloadEvent
+	FileLoaderOptions               = mtls.FileLoaderOptions
+	CRLLoader                       = mtls.CRLLoader
+	CRLLoaderOptions                = mtls.CRLLoaderOptions
+	CRLReloadEvent                  = mtls.CRLReloadEvent
+	CRLSet                          = mtls.CRLSet
+	OCSPStaplingOptions             = mtls.OCSPStaplingOptions
+	CryptoPolicy                    = mtls.CryptoPolicy
+	KeyPairValidator                = mtls.KeyPairValidator
+	ValidationCheck                 = mtls.ValidationCheck
+	ValidationReport                = mtls.ValidationReport
+	ValidationResult                = mtls.ValidationResult
+	CABundlePolicy                  = mtls.CABundlePolicy
+	CABundleAction                  = mtls.CABundleAction
+	CABundleIssue                   = mtls.CABundleIssue
+	CABundleFinding                 = mtls.CABundleFinding
+	KeyPairLoader                   = mtls.KeyPairLoader
+	ServerIdentity                  = mtls.ServerIdentity
+	SNIServerTLSConfigLoader        = mtls.SNIServerTLSConfigLoader
+	SNIServerTLSConfigLoaderOptions = mtls.SNIServerTLSConfigLoaderOptions
+	ClientAuthMode                  = mtls.ClientAuthMode
+	CABundleLoader                  = mtls.CABundleLoader
+	CABundleLoaderOptions           = mtls.CABundleLoaderOptions
+	CABundleReloadEvent             = mtls.CABundleReloadEvent
+	PeerIdentity                    = mtls.PeerIdentity
+	GRPCAuthorizer                  = mtls.GRPCAuthorizer
+	GRPCAuthorizerOptions           = mtls.GRPCAuthorizerOptions
+)
+
+const (
+	CABundleWarn   = mtls.CABundleWarn
+	CABundleDrop   = mtls.CABundleDrop
+	CABundleReject = mtls.CABundleReject
+
+	CABundleExpired     = mtls.CABundleExpired
+	CABundleNotYetValid = mtls.CABundleNotYetValid
+	CABundleNotCA       = mtls.CABundleNotCA
+	CABundleDuplicate   = mtls.CABundleDuplicate
+	CABundleUnparseable = mtls.CABundleUnparseable
+
+	ClientAuthDefault       = mtls.ClientAuthDefault
+	RequireClientCert       = mtls.RequireClientCert
+	VerifyClientCertIfGiven = mtls.VerifyClientCertIfGiven
+	NoClientCert            = mtls.NoClientCert
+)
+
+var (
+	// ErrValidateKeyPair is wrapped by ReloadEvent.Err when a key pair is rejected by the Validate option.
+	ErrValidateKeyPair = mtls.ErrValidateKeyPair
+	// ErrVerifyPeer is wrapped by handshake errors when a peer is rejected by a PeerVerifier.
+	ErrVerifyPeer = mtls.ErrVerifyPeer
+	// ErrPeerNotAllowed is wrapped by handshake errors when a peer is denied by a PeerPolicy.
+	ErrPeerNotAllowed = mtls.ErrPeerNotAllowed
+	// ErrCertificateRevoked is wrapped by handshake errors when a peer is revoked by a CRL.
+	ErrCertificateRevoked = mtls.ErrCertificateRevoked
+	// ErrStaleCRL is wrapped by handshake errors when the CRL of a peer's issuer is past its next update.
+	ErrStaleCRL = mtls.ErrStaleCRL
+	// ErrMissingOCSPStaple is wrapped by handshake errors when a server doesn't staple a required OCSP response.
+	ErrMissingOCSPStaple = mtls.ErrMissingOCSPStaple
+	// ErrInvalidOCSPResponse is wrapped by handshake errors when a stapled OCSP response can't be trusted.
+	ErrInvalidOCSPResponse = mtls.ErrInvalidOCSPResponse
+	// ErrIncompleteCertificateChain is wrapped by validation errors when the leaf can't be chained up to the CA bundle.
+	ErrIncompleteCertificateChain = mtls.ErrIncompleteCertificateChain
+	// ErrCryptoPolicy is wrapped by validation and handshake errors when a certificate violates a CryptoPolicy.
+	ErrCryptoPolicy = mtls.ErrCryptoPolicy
+	// ErrCABundleRejected is wrapped by ReloadEvent.Err when the CABundlePolicy rejects the CA bundle.
+	ErrCABundleRejected = mtls.ErrCABundleRejected
+)
+
+// PassphraseFromFile returns a PassphraseProvider that reads the passphrase of an
+// encrypted private key or PKCS#12 keystore from a file. Local file loaders watch the file.
+func PassphraseFromFile(path string) PassphraseProvider {
+	return mtls.PassphraseFromFile(path)
+}
+
+// PassphraseFromEnv returns a PassphraseProvider that reads the passphrase of an
+// encrypted private key or PKCS#12 keystore from an environment variable.
+func PassphraseFromEnv(name string) PassphraseProvider {
+	return mtls.PassphraseFromEnv(name)
+}
 
 // NewLocalFileClientTLSConfigLoader creates a ClientTLSConfigLoader that
 // loads TLS certificates from local files with automatic reloading.
@@ -48,3 +169,200 @@ func NewLocalFileClientTLSConfigLoader(options LocalFileTLSConfigLoaderOptions)
 func NewLocalFileServerTLSConfigLoader(options LocalFileTLSConfigLoaderOptions) (ServerTLSLoader, error) {
 	return mtls.NewLocalFileServerTLSConfigLoader(options)
 }
+
+// NewTLSKeyPairFromPEM parses a PEM encoded CA bundle, certificate chain and private key.
+func NewTLSKeyPairFromPEM(caPEM, certPEM, keyPEM []byte) (*TLSKeyPair, error) {
+	return mtls.NewTLSKeyPairRaw(caPEM, certPEM, keyPEM).Parse()
+}
+
+// NewStaticClientTLSConfigLoader creates a ClientTLSLoader that holds the key pair in memory.
+// New key pairs are validated for client usage and pushed with Set or SetPEM.
+func NewStaticClientTLSConfigLoader(keyPair *TLSKeyPair, verifiers ...PeerVerifier) (*StaticClientTLSConfigLoader, error) {
+	return mtls.NewStaticClientTLSConfigLoader(keyPair, verifiers...)
+}
+
+// NewStaticServerTLSConfigLoader creates a ServerTLSLoader that holds the key pair in memory.
+// New key pairs are validated for server usage and pushed with Set or SetPEM.
+func NewStaticServerTLSConfigLoader(keyPair *TLSKeyPair, verifiers ...PeerVerifier) (*StaticServerTLSConfigLoader, error) {
+	return mtls.NewStaticServerTLSConfigLoader(keyPair, verifiers...)
+}
+
+// NewTLSKeyPairRaw holds a PEM encoded CA bundle, certificate chain and private key
+// as read by a KeyPairSource.
+func NewTLSKeyPairRaw(caPEM, certPEM, keyPEM []byte) *TLSKeyPairRaw {
+	return mtls.NewTLSKeyPairRaw(caPEM, certPEM, keyPEM)
+}
+
+// NewSourceClientTLSConfigLoader creates a ClientTLSLoader that loads TLS certificates
+// from a custom KeyPairSource with automatic reloading.
+func NewSourceClientTLSConfigLoader(source KeyPairSource, options SourceTLSConfigLoaderOptions) (ClientTLSLoader, error) {
+	return mtls.NewSourceClientTLSConfigLoader(source, options)
+}
+
+// NewSourceServerTLSConfigLoader creates a ServerTLSLoader that loads TLS certificates
+// from a custom KeyPairSource with automatic reloading.
+func NewSourceServerTLSConfigLoader(source KeyPairSource, options SourceTLSConfigLoaderOptions) (ServerTLSLoader, error) {
+	return mtls.NewSourceServerTLSConfigLoader(source, options)
+}
+
+// NewKubernetesSecretSource creates a KeyPairSource that reads and watches a kubernetes.io/tls
+// Secret, and optionally a CA bundle ConfigMap, through the Kubernetes API server.
+// Pass it to NewSourceClientTLSConfigLoader or NewSourceServerTLSConfigLoader.
+func NewKubernetesSecretSource(options KubernetesSecretSourceOptions) (WatchableKeyPairSource, error) {
+	return mtls.NewKubernetesSecretSource(options)
+}
+
+// VerifyPeerSPIFFEID returns a PeerVerifier that accepts peers whose certificate carries a
+// SPIFFE ID matching any of the patterns, e.g. spiffe://example.org/ns/*/sa/client.
+// Pass it through the PeerVerifiers option of the loaders.
+func VerifyPeerSPIFFEID(patterns ...string) (PeerVerifier, error) {
+	return mtls.VerifyPeerSPIFFEID(patterns...)
+}
+
+// ParseSPIFFEID parses a spiffe://trust-domain/path identity.
+func ParseSPIFFEID(raw string) (SPIFFEID, error) {
+	return mtls.ParseSPIFFEID(raw)
+}
+
+// PeerSPIFFEID returns the SPIFFE ID of the peer certificate of a connection.
+func PeerSPIFFEID(state tls.ConnectionState) (SPIFFEID, error) {
+	return mtls.PeerSPIFFEID(state)
+}
+
+// NewPeerPolicyLoader loads a peer allowlist from a JSON policy file, and reloads it when
+// the file changes. Pass its VerifyPeer method through the PeerVerifiers option of a
+// server loader, and run its StartLoop next to the loader's.
+func NewPeerPolicyLoader(options PeerPolicyLoaderOptions) (*PeerPolicyLoader, error) {
+	return mtls.NewPeerPolicyLoader(options)
+}
+
+// NewCRLLoader loads the certificate revocation lists of a file or directory, and reloads
+// them when they change. Pass its VerifyPeer method through the PeerVerifiers option of a
+// client or server loader, and run its StartLoop next to the loader's.
+func NewCRLLoader(options CRLLoaderOptions) (*CRLLoader, error) {
+	return mtls.NewCRLLoader(options)
+}
+
+// VerifyPeerOCSPStaple returns a PeerVerifier for client loaders that validates the OCSP
+// response stapled by the server. Servers that don't staple are rejected if requireStaple
+// is set or their certificate is must-staple.
+func VerifyPeerOCSPStaple(requireStaple bool) PeerVerifier {
+	return mtls.VerifyPeerOCSPStaple(requireStaple)
+}
+
+// DefaultCryptoPolicy returns the fleet-wide CryptoPolicy.
+func DefaultCryptoPolicy() CryptoPolicy {
+	return mtls.DefaultCryptoPolicy()
+}
+
+// VerifyPeerCryptoPolicy returns a PeerVerifier that rejects peers whose verified chain
+// violates the policy.
+func VerifyPeerCryptoPolicy(policy CryptoPolicy) PeerVerifier {
+	return mtls.VerifyPeerCryptoPolicy(policy)
+}
+
+// NewSNIServerTLSConfigLoader creates a ServerTLSLoader that serves several identities,
+// picked by the server name the client asks for. The server loaders returned by
+// NewLocalFileServerTLSConfigLoader, NewSourceServerTLSConfigLoader and NewStaticServerTLSConfigLoader
+// implement KeyPairLoader, and can be used as identities; their PeerVerifiers and ClientAuth
+// keep applying to the clients of the identity.
+func NewSNIServerTLSConfigLoader(options SNIServerTLSConfigLoaderOptions) (*SNIServerTLSConfigLoader, error) {
+	return mtls.NewSNIServerTLSConfigLoader(options)
+}
+
+// RequirePeerCertificate returns an HTTP middleware that rejects requests with 401 unless the
+// client presented a verified certificate, and with 403 if any of the verifiers rejects it.
+// Use it on the routes that need client certificates, with a server loader whose ClientAuth
+// option is VerifyClientCertIfGiven.
+func RequirePeerCertificate(verifiers ...PeerVerifier) func(http.Handler) http.Handler {
+	return mtls.RequirePeerCertificate(verifiers...)
+}
+
+// NewPeerIdentity returns the identity of a peer certificate.
+func NewPeerIdentity(cert *x509.Certificate) *PeerIdentity {
+	return mtls.NewPeerIdentity(cert)
+}
+
+// PeerIdentityFromConnectionState returns the identity of the peer of a connection, if it
+// presented a certificate that was verified.
+func PeerIdentityFromConnectionState(state tls.ConnectionState) (*PeerIdentity, bool) {
+	return mtls.PeerIdentityFromConnectionState(state)
+}
+
+// ContextWithPeerIdentity returns a copy of the context carrying the identity.
+func ContextWithPeerIdentity(ctx context.Context, id *PeerIdentity) context.Context {
+	return mtls.ContextWithPeerIdentity(ctx, id)
+}
+
+// PeerIdentityFromContext returns the identity of the client put in the context by
+// PeerIdentityMiddleware.
+func PeerIdentityFromContext(ctx context.Context) (*PeerIdentity, bool) {
+	return mtls.PeerIdentityFromContext(ctx)
+}
+
+// PeerIdentityMiddleware puts the identity of the client in the request context, if the
+// client presented a verified certificate. Read it with PeerIdentityFromContext.
+func PeerIdentityMiddleware(next http.Handler) http.Handler {
+	return mtls.PeerIdentityMiddleware(next)
+}
+
+// NewGRPCAuthorizer creates the unary and stream interceptors of a gRPC server that put the
+// PeerIdentity of the client in the context, and authorize the calls by method and identity:
+//
+//	authorizer, err := NewGRPCAuthorizer(GRPCAuthorizerOptions{
+//		Methods: map[string][]PeerVerifier{"/pkg.Admin/*": {verifyOps}},
+//		Public:  []string{"/grpc.health.v1.Health/Check"},
+//	})
+//	server := grpc.NewServer(
+//		grpc.ChainUnaryInterceptor(authorizer.UnaryServerInterceptor()),
+//		grpc.ChainStreamInterceptor(authorizer.StreamServerInterceptor()),
+//	)
+func NewGRPCAuthorizer(options GRPCAuthorizerOptions) (*GRPCAuthorizer, error) {
+	return mtls.NewGRPCAuthorizer(options)
+}
+
+// NewCABundleLoader creates a loader that keeps a CA bundle file up to date, e.g. to verify
+// the clients of one ServerIdentity.
+func NewCABundleLoader(options CABundleLoaderOptions) (*CABundleLoader, error) {
+	return mtls.NewCABundleLoader(options)
+}
+
+// ComposeValidators returns a KeyPairValidator that runs all the checks, e.g.
+//
+//	ComposeValidators(
+//		ValidateExpiryFraction(0.2),
+//		ValidateExtKeyUsage(x509.ExtKeyUsageServerAuth),
+//		ValidateChain(x509.ExtKeyUsageServerAuth),
+//		ValidateSANCoverage("api.internal"),
+//	)
+//
+// It fails with a *ValidationReport listing the outcome of every check.
+func ComposeValidators(checks ...ValidationCheck) KeyPairValidator {
+	return mtls.ComposeValidators(checks...)
+}
+
+// ValidateExpiry returns a ValidationCheck that rejects leaves expiring within minRemaining.
+func ValidateExpiry(minRemaining time.Duration) ValidationCheck {
+	return mtls.ValidateExpiry(minRemaining)
+}
+
+// ValidateExpiryFraction returns a ValidationCheck that rejects leaves with less than the
+// fraction of their lifetime left.
+func ValidateExpiryFraction(fraction float64) ValidationCheck {
+	return mtls.ValidateExpiryFraction(fraction)
+}
+
+// ValidateExtKeyUsage returns a ValidationCheck that rejects leaves without the extended key usage.
+func ValidateExtKeyUsage(usage x509.ExtKeyUsage) ValidationCheck {
+	return mtls.ValidateExtKeyUsage(usage)
+}
+
+// ValidateChain returns a ValidationCheck that rejects leaves not chaining up to the CA bundle.
+func ValidateChain(usage x509.ExtKeyUsage) ValidationCheck {
+	return mtls.ValidateChain(usage)
+}
+
+// ValidateSANCoverage returns a ValidationCheck that rejects leaves not valid for all the names.
+func ValidateSANCoverage(names ...string) ValidationCheck {
+	return mtls.ValidateSANCoverage(names...)
+}
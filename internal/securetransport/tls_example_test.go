@@ -7,7 +7,6 @@ import (
 	"time"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
@@ -126,7 +125,7 @@ func ExampleNewLocalFileServerTLSConfigLoader_forGRPCServer() {
 	}()
 
 	server := grpc.NewServer(
-		grpc.Creds(credentials.NewTLS(loader.ServerTLSConfig())),
+		grpc.Creds(loader.GRPCServerCredentials()),
 	)
 
 	lis, err := net.Listen("tcp", ":8080")
@@ -139,3 +138,32 @@ func ExampleNewLocalFileServerTLSConfigLoader_forGRPCServer() {
 		panic(err)
 	}
 }
+
+func ExampleNewLocalFileServerTLSConfigLoader_fromPKCS12() {
+	loader, err := NewLocalFileServerTLSConfigLoader(LocalFileTLSConfigLoaderOptions{
+		PKCS12:         "tls.p12",
+		KeyPassphrase:  PassphraseFromFile("tls.p12.password"),
+		ReloadInterval: 10 * time.Second,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := loader.StartLoop(ctx); err != nil {
+			panic(err)
+		}
+	}()
+
+	server := http.Server{
+		Addr:      ":8080",
+		TLSConfig: loader.ServerTLSConfig(),
+	}
+
+	if err := server.ListenAndServeTLS("", ""); err != nil {
+		panic(err)
+	}
+}